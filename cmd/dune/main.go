@@ -3,18 +3,121 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"io/fs"
+	"log"
+	"time"
+
+	"github.com/gfeyer/ebit/internal/editor"
+	"github.com/gfeyer/ebit/internal/fog"
 	"github.com/gfeyer/ebit/internal/game"
+	"github.com/gfeyer/ebit/internal/mapfile"
+	"github.com/gfeyer/ebit/internal/netplay/client"
+	"github.com/gfeyer/ebit/internal/replay"
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
 func main() {
+	seed := flag.Uint64("seed", 0, "seed for the random number generator (0 picks one at random)")
+	record := flag.String("record", "", "record the match to this replay file")
+	replayFile := flag.String("replay", "", "play back the match in this replay file")
+	mapPath := flag.String("map", "", "play on the map in this file instead of a generated one")
+	edit := flag.Bool("edit", false, "edit the map given with -map instead of playing; a missing file starts from a generated map")
+	connect := flag.String("connect", "", "join a network match through the relay at this URL, e.g. ws://localhost:8080/ws")
+	fogName := flag.String("fog", "dynamic", "fog of war mode: dynamic, classic or off (F7 cycles through them in game)")
+	flag.Parse()
+
+	fogMode, err := fog.ParseMode(*fogName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	const W, H = 1280, 720
 	ebiten.SetWindowSize(W, H)
 	ebiten.SetWindowTitle("Dune II")
 	ebiten.SetTPS(60)
 
-	g := game.NewGame(W, H)
-	if err := ebiten.RunGame(g); err != nil {
+	if *edit {
+		runEditor(W, H, *mapPath, *seed)
+		return
+	}
+
+	var g *game.Game
+	if *connect != "" {
+		log.Printf("waiting for another player at %s", *connect)
+		c, err := client.Connect(*connect, game.DefsHash())
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer c.Close()
+		log.Printf("match started: commanding faction %d (seed %d)", c.Welcome.Faction, c.Welcome.Seed)
+		if g, err = game.NewNetworkGame(W, H, c); err != nil {
+			log.Fatal(err)
+		}
+		if *record != "" {
+			g.StartRecording()
+		}
+	} else if *replayFile != "" {
+		r, err := replay.Load(*replayFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("playing back %s (seed %d)", *replayFile, r.Seed)
+		if g, err = game.NewReplayGame(r); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		if *seed == 0 {
+			*seed = uint64(time.Now().UnixNano())
+		}
+		log.Printf("seed: %d", *seed)
+		var m *mapfile.Map
+		if *mapPath != "" {
+			var err error
+			if m, err = mapfile.Load(*mapPath); err != nil {
+				log.Fatal(err)
+			}
+		}
+		g = game.NewGame(W, H, *seed, m)
+		if *record != "" {
+			g.StartRecording()
+		}
+	}
+
+	g.SetFogMode(fogMode)
+
+	err = ebiten.RunGame(g)
+	if *record != "" && *replayFile == "" {
+		if err := g.SaveRecording(*record); err != nil {
+			log.Printf("saving replay: %v", err)
+		} else {
+			log.Printf("replay saved to %s", *record)
+		}
+	}
+	if err != nil {
+		panic(err)
+	}
+}
+
+// runEditor edits the map in path, or a map generated from seed if the file doesn't exist yet.
+func runEditor(w, h int, path string, seed uint64) {
+	if path == "" {
+		log.Fatal("-edit needs the file to edit, given with -map")
+	}
+	m, err := mapfile.Load(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if seed == 0 {
+			seed = uint64(time.Now().UnixNano())
+		}
+		log.Printf("%s doesn't exist yet; starting from a map generated with seed %d", path, seed)
+		m = game.GenerateMap(w, h, seed)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	ebiten.SetWindowTitle("Dune II - " + path)
+	if err := ebiten.RunGame(editor.New(w, h, m, path)); err != nil {
 		panic(err)
 	}
 }
@@ -3,7 +3,13 @@
 package main
 
 import (
+	"log"
+	"strings"
+	"syscall/js"
+	"time"
+
 	"github.com/gfeyer/ebit/internal/game"
+	"github.com/gfeyer/ebit/internal/netplay/client"
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
@@ -11,7 +17,30 @@ func main() {
 	const W, H = 1280, 720
 	ebiten.SetTPS(60)
 
-	g := game.NewGame(W, H)
+	// Opening the page with ?connect joins a network match through the relay of the server that serves the page.
+	// Pages served over https connect over wss, as browsers don't allow insecure connections from them.
+	var g *game.Game
+	location := js.Global().Get("location")
+	if strings.Contains(location.Get("search").String(), "connect") {
+		scheme := "ws://"
+		if location.Get("protocol").String() == "https:" {
+			scheme = "wss://"
+		}
+		url := scheme + location.Get("host").String() + "/ws"
+		log.Printf("waiting for another player at %s", url)
+		c, err := client.Connect(url, game.DefsHash())
+		if err != nil {
+			panic(err)
+		}
+		log.Printf("match started: commanding faction %d (seed %d)", c.Welcome.Faction, c.Welcome.Seed)
+		if g, err = game.NewNetworkGame(W, H, c); err != nil {
+			panic(err)
+		}
+	} else {
+		seed := uint64(time.Now().UnixNano())
+		log.Printf("seed: %d", seed)
+		g = game.NewGame(W, H, seed, nil)
+	}
 	if err := ebiten.RunGame(g); err != nil {
 		panic(err)
 	}
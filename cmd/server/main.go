@@ -3,11 +3,15 @@ package main
 import (
 	"log"
 	"net/http"
+
+	"github.com/gfeyer/ebit/internal/netplay"
 )
 
 func main() {
 	fs := http.FileServer(http.Dir("./web"))
 	http.Handle("/", fs)
+	// Clients connecting here are paired up into two-player matches.
+	http.Handle("/ws", netplay.NewRelay(2))
 
 	log.Println("Listening on :8080...")
 	err := http.ListenAndServe(":8080", nil)
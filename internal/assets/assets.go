@@ -0,0 +1,149 @@
+// Package assets loads the game's sprites from the PNG sprite sheets embedded in the binary.
+// sprites.json lists every sprite: the sheet it is on, where its first frame is and how many frames it has.
+// Frames of a sprite lie side by side; a vehicle has one frame per direction, clockwise starting from facing up.
+//
+// Pixels in shades of magenta (equal red and blue, no green) are team colors: they are recolored with the
+// color of the faction that owns the entity, keeping their brightness.
+package assets
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/png"
+	"log"
+	"sync"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+//go:embed sprites.json *.png
+var files embed.FS
+
+// Sprite describes where a sprite's frames are on a sheet. X, Y, Width and Height are in pixels.
+type Sprite struct {
+	Name   string
+	Sheet  string
+	X, Y   int
+	Width  int
+	Height int
+	Frames int
+}
+
+// key identifies a sprite as it is drawn: recolored for a team and scaled to a size.
+type key struct {
+	name          string
+	team          color.RGBA
+	width, height int
+}
+
+var (
+	loadOnce sync.Once
+	sprites  map[string]Sprite
+	sheets   map[string]*image.RGBA
+
+	mu    sync.Mutex
+	cache = map[key][]*ebiten.Image{}
+)
+
+// load reads the metadata and decodes the sheets it refers to. Anything that fails to load is logged and left out,
+// so the sprites on it fall back to the shapes drawn by the factory.
+func load() {
+	sprites = map[string]Sprite{}
+	sheets = map[string]*image.RGBA{}
+
+	data, err := files.ReadFile("sprites.json")
+	if err != nil {
+		log.Printf("assets: %v", err)
+		return
+	}
+	var meta struct{ Sprites []Sprite }
+	if err := json.Unmarshal(data, &meta); err != nil {
+		log.Printf("assets: decoding sprites.json: %v", err)
+		return
+	}
+
+	for _, s := range meta.Sprites {
+		if _, ok := sheets[s.Sheet]; !ok {
+			sheet, err := decodeSheet(s.Sheet)
+			if err != nil {
+				log.Printf("assets: %v", err)
+			}
+			sheets[s.Sheet] = sheet
+		}
+		if sheet := sheets[s.Sheet]; sheet != nil && s.Frames > 0 &&
+			image.Rect(s.X, s.Y, s.X+s.Width*s.Frames, s.Y+s.Height).In(sheet.Bounds()) {
+			sprites[s.Name] = s
+		} else {
+			log.Printf("assets: sprite %q doesn't fit on %s", s.Name, s.Sheet)
+		}
+	}
+}
+
+// decodeSheet decodes an embedded PNG sheet.
+func decodeSheet(name string) (*image.RGBA, error) {
+	f, err := files.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", name, err)
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return rgba, nil
+}
+
+// Frames returns the frames of a sprite with its team colors replaced by team, each scaled to width by height.
+// It returns nil if there is no such sprite. Entities drawn with the same sprite, team and size share the images.
+func Frames(name string, team color.RGBA, width, height int) []*ebiten.Image {
+	loadOnce.Do(load)
+	s, ok := sprites[name]
+	if !ok || width <= 0 || height <= 0 {
+		return nil
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	k := key{name: name, team: team, width: width, height: height}
+	if frames, ok := cache[k]; ok {
+		return frames
+	}
+
+	sheet := sheets[s.Sheet]
+	frames := make([]*ebiten.Image, s.Frames)
+	for i := range frames {
+		frame := image.NewRGBA(image.Rect(0, 0, s.Width, s.Height))
+		draw.Draw(frame, frame.Bounds(), sheet, image.Pt(s.X+i*s.Width, s.Y), draw.Src)
+		recolor(frame, team)
+
+		img := ebiten.NewImageFromImage(frame)
+		if s.Width != width || s.Height != height {
+			scaled := ebiten.NewImage(width, height)
+			op := &ebiten.DrawImageOptions{}
+			op.GeoM.Scale(float64(width)/float64(s.Width), float64(height)/float64(s.Height))
+			scaled.DrawImage(img, op)
+			img = scaled
+		}
+		frames[i] = img
+	}
+	cache[k] = frames
+	return frames
+}
+
+// recolor replaces the team color pixels of an image with shades of team.
+func recolor(img *image.RGBA, team color.RGBA) {
+	for i := 0; i < len(img.Pix); i += 4 {
+		r, g, b := img.Pix[i], img.Pix[i+1], img.Pix[i+2]
+		if r == b && g == 0 && r > 0 {
+			img.Pix[i] = uint8(int(team.R) * int(r) / 255)
+			img.Pix[i+1] = uint8(int(team.G) * int(r) / 255)
+			img.Pix[i+2] = uint8(int(team.B) * int(r) / 255)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package camera
 
 import (
+	"math"
+
 	"github.com/gfeyer/ebit/internal/components"
+	"github.com/gfeyer/ebit/internal/input"
 	"github.com/gfeyer/ebit/internal/settings"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/yohamta/donburi"
@@ -9,14 +12,90 @@ import (
 	"github.com/yohamta/donburi/filter"
 )
 
-// Camera represents the game's camera.
+const (
+	// MinZoom and MaxZoom limit how far the camera zooms out and in. The camera never zooms out further than
+	// needed to show the whole map.
+	MinZoom = 0.25
+	MaxZoom = 2.0
+	// zoomStep is how much one notch of the mouse wheel or one press of + or - zooms.
+	zoomStep = 1.25
+)
+
+// Camera represents the game's camera. X and Y are the world position shown in the top-left corner of the screen.
 type Camera struct {
 	X, Y float64
+	// Zoom is how many screen pixels a world pixel covers. Zero counts as 1, so cameras saved before the camera
+	// could zoom load unzoomed.
+	Zoom float64
+}
+
+// Scale returns how many screen pixels a world pixel covers.
+func (c *Camera) Scale() float64 {
+	if c.Zoom <= 0 {
+		return 1
+	}
+	return c.Zoom
 }
 
 // ScreenToWorld converts screen coordinates to world coordinates.
 func (c *Camera) ScreenToWorld(x, y float64) (float64, float64) {
-	return x + c.X, y + c.Y
+	s := c.Scale()
+	return x/s + c.X, y/s + c.Y
+}
+
+// WorldToScreen converts world coordinates to screen coordinates.
+func (c *Camera) WorldToScreen(x, y float64) (float64, float64) {
+	s := c.Scale()
+	return (x - c.X) * s, (y - c.Y) * s
+}
+
+// GeoM returns the transform from world to screen coordinates. Renderers concatenate it to the transform that
+// places an image in the world.
+func (c *Camera) GeoM() ebiten.GeoM {
+	var g ebiten.GeoM
+	g.Translate(-c.X, -c.Y)
+	g.Scale(c.Scale(), c.Scale())
+	return g
+}
+
+// ViewSize returns the width and height of the part of the world the screen shows.
+func (c *Camera) ViewSize(s *settings.Settings) (float64, float64) {
+	return float64(s.ScreenWidth) / c.Scale(), float64(s.ScreenHeight) / c.Scale()
+}
+
+// CenterOn moves the camera so the world position (x, y) is in the middle of the screen, as far as the map allows.
+func (c *Camera) CenterOn(s *settings.Settings, x, y float64) {
+	viewW, viewH := c.ViewSize(s)
+	c.X, c.Y = x-viewW/2, y-viewH/2
+	c.Clamp(s)
+}
+
+// ZoomAt multiplies the zoom by factor, keeping the world position under the screen position (x, y) in place.
+func (c *Camera) ZoomAt(s *settings.Settings, factor, x, y float64) {
+	wx, wy := c.ScreenToWorld(x, y)
+	c.Zoom = c.Scale() * factor
+	c.Clamp(s)
+	c.X, c.Y = wx-x/c.Scale(), wy-y/c.Scale()
+	c.Clamp(s)
+}
+
+// Clamp keeps the zoom within its limits and the view within the map. A map narrower or shorter than the view is
+// centered on the screen.
+func (c *Camera) Clamp(s *settings.Settings) {
+	minZoom := math.Max(MinZoom, math.Min(1, math.Min(float64(s.ScreenWidth)/float64(s.MapWidth), float64(s.ScreenHeight)/float64(s.MapHeight))))
+	c.Zoom = math.Min(math.Max(c.Scale(), minZoom), MaxZoom)
+
+	viewW, viewH := c.ViewSize(s)
+	c.X = clampAxis(c.X, viewW, float64(s.MapWidth))
+	c.Y = clampAxis(c.Y, viewH, float64(s.MapHeight))
+}
+
+// clampAxis keeps a view of the given length starting at pos within a map of the given length.
+func clampAxis(pos, view, length float64) float64 {
+	if view >= length {
+		return (length - view) / 2
+	}
+	return math.Min(math.Max(pos, 0), length-view)
 }
 
 var CameraRes = donburi.NewComponentType[Camera]()
@@ -25,15 +104,19 @@ var (
 	CameraQuery = donburi.NewQuery(filter.Contains(CameraRes))
 )
 
-// Update handles camera movement.
+// Update handles camera movement and zoom.
 func Update(ecs *ecs.ECS) {
+	in := input.GetInput(ecs.World)
 	cameraEntry, _ := CameraQuery.First(ecs.World)
 	cam := CameraRes.Get(cameraEntry)
 
 	settings := settings.GetSettings(ecs.World)
 
+	// Panning moves the view by the same number of screen pixels at every zoom.
+	scrollSpeed := 5.0 / cam.Scale()
+
 	// Pan with mouse at screen edges
-	mx, my := ebiten.CursorPosition()
+	mx, my := in.CursorPosition()
 
 	minimapEntry, ok := donburi.NewQuery(filter.Contains(components.MinimapRes)).First(ecs.World)
 	if ok {
@@ -41,7 +124,6 @@ func Update(ecs *ecs.ECS) {
 		inMinimap := mx >= minimap.X && mx < minimap.X+minimap.Width && my >= minimap.Y && my < minimap.Y+minimap.Height
 
 		scrollMargin := 20
-		scrollSpeed := 5.0
 
 		if mx < scrollMargin {
 			cam.X -= scrollSpeed
@@ -58,30 +140,33 @@ func Update(ecs *ecs.ECS) {
 	}
 
 	// Pan with arrow keys
-	if ebiten.IsKeyPressed(ebiten.KeyLeft) {
-		cam.X -= 5
+	if in.IsKeyPressed(ebiten.KeyLeft) {
+		cam.X -= scrollSpeed
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyRight) {
-		cam.X += 5
+	if in.IsKeyPressed(ebiten.KeyRight) {
+		cam.X += scrollSpeed
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyUp) {
-		cam.Y -= 5
+	if in.IsKeyPressed(ebiten.KeyUp) {
+		cam.Y -= scrollSpeed
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyDown) {
-		cam.Y += 5
+	if in.IsKeyPressed(ebiten.KeyDown) {
+		cam.Y += scrollSpeed
 	}
 
-	// Clamp camera to map boundaries
-	if cam.X < 0 {
-		cam.X = 0
+	// Zoom with the mouse wheel around the cursor, and with + and - around the middle of the screen.
+	// Shift+wheel is left to the screen, e.g. the map editor resizes spice fields with it.
+	shift := in.IsKeyPressed(ebiten.KeyShift)
+	if _, dy := in.Wheel(); dy != 0 && !shift {
+		cam.ZoomAt(settings, math.Pow(zoomStep, dy), float64(mx), float64(my))
 	}
-	if cam.Y < 0 {
-		cam.Y = 0
+	centerX, centerY := float64(settings.ScreenWidth)/2, float64(settings.ScreenHeight)/2
+	if in.IsKeyJustPressed(ebiten.KeyEqual) || in.IsKeyJustPressed(ebiten.KeyNumpadAdd) {
+		cam.ZoomAt(settings, zoomStep, centerX, centerY)
 	}
-	if cam.X > float64(settings.MapWidth-settings.ScreenWidth) {
-		cam.X = float64(settings.MapWidth - settings.ScreenWidth)
-	}
-	if cam.Y > float64(settings.MapHeight-settings.ScreenHeight) {
-		cam.Y = float64(settings.MapHeight - settings.ScreenHeight)
+	if in.IsKeyJustPressed(ebiten.KeyMinus) || in.IsKeyJustPressed(ebiten.KeyNumpadSubtract) {
+		cam.ZoomAt(settings, 1/zoomStep, centerX, centerY)
 	}
+
+	// Clamp camera to map boundaries
+	cam.Clamp(settings)
 }
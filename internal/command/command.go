@@ -0,0 +1,78 @@
+// Package command defines the orders players give to the game. Input handlers, the AI, scripts and
+// network peers never change the game state directly: they issue commands into the world's Buffer, and a
+// single system validates and applies them once per tick.
+package command
+
+import (
+	"github.com/gfeyer/ebit/internal/components"
+	"github.com/yohamta/donburi"
+	"github.com/yohamta/donburi/filter"
+)
+
+// Kind is the type of a command.
+type Kind int
+
+const (
+	// Move sends Units to (X, Y).
+	Move Kind = iota
+	// Harvest sends the harvesters among Units to gather spice from the spice field Target.
+	Harvest
+	// Attack sends the armed units among Units to attack Target.
+	Attack
+	// Stop makes Units drop whatever they were doing.
+	Stop
+	// TrainUnit adds a unit of UnitType to the production queue of the building Target.
+	TrainUnit
+	// CancelUnit removes the last queued unit of UnitType from the building Target and refunds it.
+	CancelUnit
+	// StartConstruction starts constructing a building of BuildingType.
+	StartConstruction
+	// CancelConstruction stops the construction in progress and refunds it.
+	CancelConstruction
+	// PlaceBuilding places the finished construction with its top-left corner at (X, Y).
+	PlaceBuilding
+)
+
+// Command is an order given by a faction. Which fields are used depends on the kind of command.
+type Command struct {
+	Kind         Kind
+	Faction      components.Faction
+	Units        []donburi.Entity        `json:",omitempty"`
+	Target       donburi.Entity          `json:",omitempty"`
+	X, Y         float64                 `json:",omitempty"`
+	UnitType     components.UnitType     `json:",omitempty"`
+	BuildingType components.BuildingType `json:",omitempty"`
+}
+
+// Buffer is a resource that collects the commands of the current tick.
+type Buffer struct {
+	// Tick is the number of ticks whose commands have been processed.
+	Tick int
+	// Local holds the commands issued on this machine during the current tick.
+	Local []Command
+	// Incoming holds commands from other sources, such as network peers or a replay, to apply this tick.
+	Incoming []Command
+	// Applied holds the commands that were applied in the last processed tick, in the order they were applied.
+	Applied []Command
+	// HoldLocal keeps local commands out of the simulation. Instead of being applied they are moved to
+	// Outgoing, where the owner of the game can pick them up or discard them.
+	HoldLocal bool
+	// Outgoing holds the local commands that were held back in the last processed tick.
+	Outgoing []Command
+}
+
+var BufferRes = donburi.NewComponentType[Buffer]()
+
+var BufferQuery = donburi.NewQuery(filter.Contains(BufferRes))
+
+// GetBuffer gets the command buffer from the world.
+func GetBuffer(w donburi.World) *Buffer {
+	entry, _ := BufferQuery.First(w)
+	return BufferRes.Get(entry)
+}
+
+// Issue adds a command to the current tick.
+func Issue(w donburi.World, cmd Command) {
+	buffer := GetBuffer(w)
+	buffer.Local = append(buffer.Local, cmd)
+}
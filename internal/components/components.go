@@ -8,6 +8,10 @@ import (
 type Pos struct{ X, Y float64 }
 type Vel struct{ X, Y float64 }
 
+// Size is the extent of an entity in pixels, measured from its position. The simulation uses it for
+// collisions, picking and placement so it never has to look at a sprite.
+type Size struct{ W, H float64 }
+
 // Unit components
 type UnitType int
 
@@ -17,8 +21,10 @@ const (
 	Harvester
 )
 
+// Unit marks an entity as a unit. Speed is in pixels per second.
 type Unit struct {
-	Type UnitType
+	Type  UnitType
+	Speed float64
 }
 
 type Selectable struct {
@@ -36,18 +42,24 @@ const (
 )
 
 type HarvesterData struct {
-	State         HarvesterState
-	TargetSpice   donburi.Entity
+	State          HarvesterState
+	TargetSpice    donburi.Entity
 	TargetRefinery donburi.Entity
-	HarvestTimer  int
-	CarriedAmount int
-	Capacity      int
+	HarvestTimer   int
+	CarriedAmount  int
+	Capacity       int
 }
 
 type Target struct {
 	X, Y float64
 }
 
+// Path holds the waypoints a unit follows to reach its Target.
+type Path struct {
+	Goal      Target
+	Waypoints []Pos
+}
+
 type Minimap struct {
 	Width, Height int
 	X, Y          int
@@ -68,6 +80,21 @@ type Health struct {
 	Max     int
 }
 
+// Weapon describes how a unit attacks. Range is in pixels, Cooldown and Reload are in ticks.
+type Weapon struct {
+	Range    float64
+	Damage   int
+	Cooldown int
+	Reload   int
+	// Flash counts down the ticks the shot tracer stays on screen after firing.
+	Flash int
+}
+
+// Attack holds the entity a unit has been ordered to attack.
+type Attack struct {
+	Target donburi.Entity
+}
+
 type Refinery struct{}
 
 type Barracks struct{}
@@ -77,53 +104,151 @@ type BuildingType int
 const (
 	BuildingRefinery BuildingType = iota
 	BuildingBarracks
+	BuildingWindtrap
+	BuildingTurret
+	BuildingConstructionYard
 )
 
+// Building marks an entity as a building.
+type Building struct {
+	Type BuildingType
+}
+
 type Placement struct {
-	IsPlacing   bool
+	IsPlacing    bool
 	BuildingType BuildingType
 	Icon         *ebiten.Image
-	Cost         int
 }
 
+// BuildInfo describes a building in the construction menu. BuildTime is in ticks.
+// Prerequisites are the buildings a player must own to construct it.
 type BuildInfo struct {
-	Type BuildingType
-	Name string
-	Cost int
-	Icon *ebiten.Image
+	Type          BuildingType
+	Name          string
+	Cost          int
+	BuildTime     int
+	Icon          *ebiten.Image
+	Prerequisites []BuildingType
 }
 
+// UnitInfo describes a unit in a building's training menu. BuildTime is in ticks.
+// RequiredBuilding is the building that trains the unit; Prerequisites are the buildings a player must own to train it.
 type UnitInfo struct {
 	Type             UnitType
 	Name             string
 	Cost             int
+	BuildTime        int
 	Icon             *ebiten.Image
 	RequiredBuilding BuildingType
+	Prerequisites    []BuildingType
+}
+
+// ProductionOrder is a unit waiting in a building's production queue, with the price paid for it.
+type ProductionOrder struct {
+	Type      UnitType
+	Cost      int
+	BuildTime int
+}
+
+// Production holds the queue of units a building has been ordered to train.
+// Progress counts the ticks already spent on the first order in the queue.
+type Production struct {
+	Queue    []ProductionOrder
+	Progress int
 }
 
+// Construction tracks the building a player is constructing. Once Ready, the building
+// waits to be placed on the map.
+type Construction struct {
+	Active    bool
+	Ready     bool
+	Type      BuildingType
+	Cost      int
+	BuildTime int
+	Progress  int
+}
+
+// Faction identifies which player owns a unit or building.
+type Faction int
+
+const (
+	FactionAtreides Faction = iota
+	FactionHarkonnen
+)
+
+// Owner marks the faction an entity belongs to.
+type Owner struct {
+	Faction Faction
+}
+
+// Player holds the state of one faction. Local is set for the player controlled from this machine.
 type Player struct {
-	Money int
+	Faction Faction
+	Money   int
+	Local   bool
+	Power   Power
+}
+
+// Power is what a player's buildings produce and consume, recomputed every tick. The player is low on power
+// when consumption exceeds production.
+type Power struct {
+	Produced int
+	Consumed int
+}
+
+// AI marks a player that is controlled by the computer and holds its decision-making state.
+type AI struct {
+	// ThinkTimer counts down the ticks until the AI makes its next round of decisions.
+	ThinkTimer int
+	// NextUnit alternates which combat unit the AI trains next.
+	NextUnit int
 }
 
 type Spice struct{}
 
+// Vision is how far a unit or building sees, in fog tiles. While Revealed, the fog counts the entity as a viewer
+// of the tiles around TileX, TileY, so the fog only changes when the entity moves to another tile.
+type Vision struct {
+	Radius       int
+	Revealed     bool
+	TileX, TileY int
+}
+
+// Frames holds the directional frames of a sprite, clockwise starting from facing up. Facing is the index of
+// the frame last drawn, kept so a unit that stops keeps facing the way it was going.
+type Frames struct {
+	Images []*ebiten.Image
+	Facing int
+}
+
 var (
-	Position      = donburi.NewComponentType[Pos]()
-	Velocity      = donburi.NewComponentType[Vel]()
-	Sprite        = donburi.NewComponentType[*ebiten.Image]()
-	UnitRes       = donburi.NewComponentType[Unit]()
-	SelectableRes = donburi.NewComponentType[Selectable]()
-	TargetRes     = donburi.NewComponentType[Target]()
-	MinimapRes    = donburi.NewComponentType[Minimap]()
-	DragRes       = donburi.NewComponentType[Drag]()
-	SpiceRes      = donburi.NewComponentType[Spice]()
-	HarvesterRes  = donburi.NewComponentType[HarvesterData]()
-	SpiceAmountRes = donburi.NewComponentType[SpiceAmount]()
-	RefineryRes   = donburi.NewComponentType[Refinery]()
-	BarracksRes   = donburi.NewComponentType[Barracks]()
-	BuildInfoRes  = donburi.NewComponentType[BuildInfo]()
-	UnitInfoRes   = donburi.NewComponentType[UnitInfo]()
-	PlacementRes  = donburi.NewComponentType[Placement]()
-	HealthRes     = donburi.NewComponentType[Health]()
-	PlayerRes     = donburi.NewComponentType[Player]()
+	Position        = donburi.NewComponentType[Pos]()
+	Velocity        = donburi.NewComponentType[Vel]()
+	Sprite          = donburi.NewComponentType[*ebiten.Image]()
+	FramesRes       = donburi.NewComponentType[Frames]()
+	SizeRes         = donburi.NewComponentType[Size]()
+	UnitRes         = donburi.NewComponentType[Unit]()
+	SelectableRes   = donburi.NewComponentType[Selectable]()
+	TargetRes       = donburi.NewComponentType[Target]()
+	PathRes         = donburi.NewComponentType[Path]()
+	MinimapRes      = donburi.NewComponentType[Minimap]()
+	DragRes         = donburi.NewComponentType[Drag]()
+	SpiceRes        = donburi.NewComponentType[Spice]()
+	HarvesterRes    = donburi.NewComponentType[HarvesterData]()
+	SpiceAmountRes  = donburi.NewComponentType[SpiceAmount]()
+	RefineryRes     = donburi.NewComponentType[Refinery]()
+	BarracksRes     = donburi.NewComponentType[Barracks]()
+	BuildingRes     = donburi.NewComponentType[Building]()
+	BuildInfoRes    = donburi.NewComponentType[BuildInfo]()
+	UnitInfoRes     = donburi.NewComponentType[UnitInfo]()
+	PlacementRes    = donburi.NewComponentType[Placement]()
+	ProductionRes   = donburi.NewComponentType[Production]()
+	ConstructionRes = donburi.NewComponentType[Construction]()
+	HealthRes       = donburi.NewComponentType[Health]()
+	WeaponRes       = donburi.NewComponentType[Weapon]()
+	AttackRes       = donburi.NewComponentType[Attack]()
+	PlayerRes       = donburi.NewComponentType[Player]()
+	OwnerRes        = donburi.NewComponentType[Owner]()
+	AIRes           = donburi.NewComponentType[AI]()
+	VisionRes       = donburi.NewComponentType[Vision]()
 )
@@ -0,0 +1,294 @@
+// Package defs holds the definitions of every unit and building: what they cost, how tough and fast they are
+// and how they look. The definitions are read from a JSON file, so the game can be rebalanced without rebuilding it.
+package defs
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/gfeyer/ebit/internal/components"
+	"github.com/yohamta/donburi"
+	"github.com/yohamta/donburi/filter"
+)
+
+// File is the file in the working directory that overrides the built-in definitions when it exists.
+const File = "defs.json"
+
+//go:embed defs.json
+var builtin []byte
+
+// unitIDs and buildingIDs map the IDs used in the definitions file to the types known to the game.
+var (
+	unitIDs = map[string]components.UnitType{
+		"trike":     components.Trike,
+		"quad":      components.Quad,
+		"harvester": components.Harvester,
+	}
+	buildingIDs = map[string]components.BuildingType{
+		"refinery": components.BuildingRefinery,
+		"barracks": components.BuildingBarracks,
+		"windtrap": components.BuildingWindtrap,
+		"turret":   components.BuildingTurret,
+		"yard":     components.BuildingConstructionYard,
+	}
+)
+
+// Sprite describes how an entity is drawn. Asset names a sprite on the embedded sprite sheets; when it is
+// missing, the entity is drawn as a Shape, "square" or "triangle", in Color, which is a "#rrggbb" color or
+// "faction" for the color of the entity's faction.
+type Sprite struct {
+	Asset string `json:",omitempty"`
+	Shape string
+	Color string
+}
+
+// Unit defines a unit type. BuildTime is in ticks, Speed in pixels per second, Size in pixels and Vision in fog tiles.
+type Unit struct {
+	// ID names the unit type in the definitions file.
+	ID        string
+	Type      components.UnitType `json:"-"`
+	Name      string
+	Cost      int
+	BuildTime int
+	Health    int
+	Speed     float64
+	Size      float64
+	Vision    int
+	// TrainedAt is the ID of the building that trains the unit.
+	TrainedAt string
+	Building  components.BuildingType `json:"-"`
+	// Requires lists the IDs of the buildings a player must own to train the unit.
+	Requires      []string                  `json:",omitempty"`
+	Prerequisites []components.BuildingType `json:"-"`
+	// Capacity is the amount of spice a harvester carries; other units leave it out.
+	Capacity int `json:",omitempty"`
+	// Weapon is the unit's weapon, or nil for unarmed units.
+	Weapon *components.Weapon `json:",omitempty"`
+	Sprite Sprite
+}
+
+// Building defines a building type. BuildTime is in ticks, Width and Height in pixels and Vision in fog tiles.
+type Building struct {
+	// ID names the building type in the definitions file.
+	ID        string
+	Type      components.BuildingType `json:"-"`
+	Name      string
+	Cost      int
+	BuildTime int
+	Health    int
+	Width     float64
+	Height    float64
+	Vision    int
+	// Power is what the building adds to its owner's power when positive and what it consumes when negative.
+	Power int
+	// Requires lists the IDs of the buildings a player must own to construct the building.
+	Requires      []string                  `json:",omitempty"`
+	Prerequisites []components.BuildingType `json:"-"`
+	// Weapon arms defensive structures; other buildings leave it out.
+	Weapon *components.Weapon `json:",omitempty"`
+	Sprite Sprite
+}
+
+// Defs is a resource that holds the definitions of every unit and building, in the order of the build menus.
+type Defs struct {
+	Units     []*Unit
+	Buildings []*Building
+}
+
+var DefsRes = donburi.NewComponentType[Defs]()
+
+var DefsQuery = donburi.NewQuery(filter.Contains(DefsRes))
+
+// GetDefs gets the definitions from the world.
+func GetDefs(w donburi.World) *Defs {
+	entry, _ := DefsQuery.First(w)
+	return DefsRes.Get(entry)
+}
+
+// Builtin returns the definitions built into the game.
+func Builtin() *Defs {
+	d, err := Parse(builtin)
+	if err != nil {
+		panic(fmt.Sprintf("built-in definitions: %v", err))
+	}
+	return d
+}
+
+// Load returns the definitions in File if it exists, and the built-in ones otherwise.
+func Load() (*Defs, error) {
+	data, err := os.ReadFile(File)
+	if errors.Is(err, os.ErrNotExist) {
+		return Builtin(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading definitions: %w", err)
+	}
+	return Parse(data)
+}
+
+// Hash returns a short fingerprint of the definitions. Network matches and replays only work with the definitions
+// they were played with, so they carry the hash to detect a mismatch.
+func (d *Defs) Hash() string {
+	data, err := json.Marshal(d)
+	if err != nil {
+		panic(fmt.Sprintf("encoding definitions: %v", err))
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Parse decodes definitions and checks that every unit and building type is defined exactly once,
+// with values the game can work with.
+func Parse(data []byte) (*Defs, error) {
+	var d Defs
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("decoding definitions: %w", err)
+	}
+
+	seenBuildings := map[string]bool{}
+	for _, b := range d.Buildings {
+		btype, ok := buildingIDs[b.ID]
+		if !ok || seenBuildings[b.ID] {
+			return nil, fmt.Errorf("unknown or duplicate building %q", b.ID)
+		}
+		seenBuildings[b.ID] = true
+		if err := b.check(); err != nil {
+			return nil, err
+		}
+		b.Type = btype
+		prerequisites, err := resolve(b.ID, b.Requires)
+		if err != nil {
+			return nil, err
+		}
+		b.Prerequisites = prerequisites
+	}
+	seenUnits := map[string]bool{}
+	for _, u := range d.Units {
+		utype, ok := unitIDs[u.ID]
+		if !ok || seenUnits[u.ID] {
+			return nil, fmt.Errorf("unknown or duplicate unit %q", u.ID)
+		}
+		seenUnits[u.ID] = true
+		u.Type = utype
+		if err := u.check(); err != nil {
+			return nil, err
+		}
+		btype, ok := buildingIDs[u.TrainedAt]
+		if !ok {
+			return nil, fmt.Errorf("unit %q is trained at unknown building %q", u.ID, u.TrainedAt)
+		}
+		u.Building = btype
+		prerequisites, err := resolve(u.ID, u.Requires)
+		if err != nil {
+			return nil, err
+		}
+		u.Prerequisites = prerequisites
+	}
+	if len(seenUnits) != len(unitIDs) || len(seenBuildings) != len(buildingIDs) {
+		return nil, errors.New("definitions are missing units or buildings")
+	}
+	return &d, nil
+}
+
+// check reports the first value of a unit definition the game can't work with.
+func (u *Unit) check() error {
+	switch {
+	case u.Cost < 0:
+		return fmt.Errorf("unit %q: cost must not be negative", u.ID)
+	case u.BuildTime <= 0:
+		return fmt.Errorf("unit %q: build time must be positive", u.ID)
+	case u.Health <= 0:
+		return fmt.Errorf("unit %q: health must be positive", u.ID)
+	case u.Speed < 0:
+		return fmt.Errorf("unit %q: speed must not be negative", u.ID)
+	case u.Size <= 0:
+		return fmt.Errorf("unit %q: size must be positive", u.ID)
+	case u.Vision < 0:
+		return fmt.Errorf("unit %q: vision must not be negative", u.ID)
+	case u.Type == components.Harvester && u.Capacity <= 0:
+		return fmt.Errorf("unit %q: capacity must be positive", u.ID)
+	}
+	return checkWeapon(u.ID, u.Weapon)
+}
+
+// check reports the first value of a building definition the game can't work with.
+func (b *Building) check() error {
+	switch {
+	case b.Cost < 0:
+		return fmt.Errorf("building %q: cost must not be negative", b.ID)
+	case b.BuildTime <= 0:
+		return fmt.Errorf("building %q: build time must be positive", b.ID)
+	case b.Health <= 0:
+		return fmt.Errorf("building %q: health must be positive", b.ID)
+	case b.Width <= 0 || b.Height <= 0:
+		return fmt.Errorf("building %q: width and height must be positive", b.ID)
+	case b.Vision < 0:
+		return fmt.Errorf("building %q: vision must not be negative", b.ID)
+	}
+	return checkWeapon(b.ID, b.Weapon)
+}
+
+// checkWeapon reports the first value of a weapon the game can't work with. Unarmed entries have no weapon.
+func checkWeapon(id string, w *components.Weapon) error {
+	switch {
+	case w == nil:
+		return nil
+	case w.Range <= 0:
+		return fmt.Errorf("%q: weapon range must be positive", id)
+	case w.Damage <= 0:
+		return fmt.Errorf("%q: weapon damage must be positive", id)
+	case w.Cooldown < 0:
+		return fmt.Errorf("%q: weapon cooldown must not be negative", id)
+	}
+	return nil
+}
+
+// resolve returns the building types of the IDs an entry requires.
+func resolve(id string, requires []string) ([]components.BuildingType, error) {
+	var types []components.BuildingType
+	for _, r := range requires {
+		btype, ok := buildingIDs[r]
+		if !ok {
+			return nil, fmt.Errorf("%q requires unknown building %q", id, r)
+		}
+		types = append(types, btype)
+	}
+	return types, nil
+}
+
+// UnitType returns the unit type an ID in the definitions names.
+func UnitType(id string) (components.UnitType, bool) {
+	t, ok := unitIDs[id]
+	return t, ok
+}
+
+// BuildingType returns the building type an ID in the definitions names.
+func BuildingType(id string) (components.BuildingType, bool) {
+	t, ok := buildingIDs[id]
+	return t, ok
+}
+
+// Unit returns the definition of a unit type.
+func (d *Defs) Unit(t components.UnitType) *Unit {
+	for _, u := range d.Units {
+		if u.Type == t {
+			return u
+		}
+	}
+	return nil
+}
+
+// Building returns the definition of a building type.
+func (d *Defs) Building(t components.BuildingType) *Building {
+	for _, b := range d.Buildings {
+		if b.Type == t {
+			return b
+		}
+	}
+	return nil
+}
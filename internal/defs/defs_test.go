@@ -0,0 +1,60 @@
+package defs
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gfeyer/ebit/internal/components"
+)
+
+func TestBuiltinDefinitionsParse(t *testing.T) {
+	if _, err := Parse(builtin); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseRejectsUnusableValues(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		id     string
+		change func(d *Defs)
+	}{
+		{"unit build time", "trike", func(d *Defs) { d.Unit(components.Trike).BuildTime = 0 }},
+		{"unit health", "trike", func(d *Defs) { d.Unit(components.Trike).Health = -5 }},
+		{"unit size", "trike", func(d *Defs) { d.Unit(components.Trike).Size = 0 }},
+		{"building build time", "yard", func(d *Defs) { d.Building(components.BuildingConstructionYard).BuildTime = -1 }},
+		{"building health", "yard", func(d *Defs) { d.Building(components.BuildingConstructionYard).Health = 0 }},
+		{"building size", "yard", func(d *Defs) { d.Building(components.BuildingConstructionYard).Width = 0 }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := Parse(builtin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.change(d)
+			data, err := json.Marshal(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = Parse(data)
+			if err == nil {
+				t.Fatal("Parse accepted the definitions")
+			}
+			if !strings.Contains(err.Error(), `"`+tc.id+`"`) {
+				t.Errorf("error %q doesn't name %q", err, tc.id)
+			}
+		})
+	}
+}
+
+func TestHashChangesWithTheDefinitions(t *testing.T) {
+	a, b := Builtin(), Builtin()
+	if a.Hash() != b.Hash() {
+		t.Fatalf("the same definitions hash to %q and %q", a.Hash(), b.Hash())
+	}
+	b.Unit(components.Trike).Speed++
+	if a.Hash() == b.Hash() {
+		t.Fatal("changing a unit's speed didn't change the hash")
+	}
+}
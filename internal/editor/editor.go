@@ -0,0 +1,363 @@
+// Package editor implements the map editor. The map being edited is drawn with the game's own renderers and
+// navigated like a match, with the camera and the minimap; spice fields, start positions and the units and
+// buildings on the map are placed with the mouse, and the result is saved as a map file.
+package editor
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"math"
+
+	"github.com/gfeyer/ebit/internal/camera"
+	"github.com/gfeyer/ebit/internal/components"
+	"github.com/gfeyer/ebit/internal/defs"
+	"github.com/gfeyer/ebit/internal/factory"
+	"github.com/gfeyer/ebit/internal/fog"
+	"github.com/gfeyer/ebit/internal/input"
+	"github.com/gfeyer/ebit/internal/mapfile"
+	"github.com/gfeyer/ebit/internal/settings"
+	"github.com/gfeyer/ebit/internal/systems"
+	"github.com/gfeyer/ebit/internal/terrain"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/yohamta/donburi"
+	"github.com/yohamta/donburi/ecs"
+	"github.com/yohamta/donburi/filter"
+	"golang.org/x/image/font/basicfont"
+)
+
+// Tool is what a left-click places.
+type Tool int
+
+const (
+	ToolSpice Tool = iota
+	ToolStart
+	ToolUnit
+	ToolBuilding
+)
+
+var toolNames = map[Tool]string{
+	ToolSpice:    "Spice",
+	ToolStart:    "Start",
+	ToolUnit:     "Unit",
+	ToolBuilding: "Building",
+}
+
+var factionNames = map[components.Faction]string{
+	components.FactionAtreides:  "Atreides",
+	components.FactionHarkonnen: "Harkonnen",
+}
+
+const (
+	// spiceAmount is the amount of spice in a new spice field.
+	spiceAmount = 2000
+	// spiceStep is how much a turn of the mouse wheel grows or shrinks a spice field, in pixels.
+	spiceStep = 16
+	minSpice  = 32
+	maxSpice  = 256
+)
+
+// qMapEntities retrieves the spice fields, units and buildings of the map.
+var qMapEntities = donburi.NewQuery(filter.Contains(components.Position))
+
+// Editor is an ebiten.Game that edits a map and saves it to a file.
+type Editor struct {
+	ecs  *ecs.ECS
+	m    *mapfile.Map
+	path string
+	defs *defs.Defs
+
+	tool    Tool
+	faction components.Faction
+	// unit and building index the definitions of the unit and building types placed next.
+	unit     int
+	building int
+	// status is the outcome of the last save.
+	status string
+}
+
+// New creates an editor for a map that is saved to path.
+func New(w, h int, m *mapfile.Map, path string) *Editor {
+	d, err := defs.Load()
+	if err != nil {
+		log.Printf("%v; using the built-in definitions", err)
+		d = defs.Builtin()
+	}
+	e := &Editor{m: m, path: path, defs: d}
+	e.ecs = newECS(w, h, m, d)
+	e.rebuild()
+	return e
+}
+
+// newECS creates a world showing a map, without fog, scrolled with the camera and the minimap.
+func newECS(w, h int, m *mapfile.Map, d *defs.Defs) *ecs.ECS {
+	world := donburi.NewWorld()
+	ecs := ecs.NewECS(world)
+	mapWidth, mapHeight := m.Width*mapfile.TileSize, m.Height*mapfile.TileSize
+
+	// Register settings
+	se := world.Create(settings.SettingsRes)
+	*settings.SettingsRes.Get(world.Entry(se)) = settings.Settings{ScreenWidth: w, ScreenHeight: h, MapWidth: mapWidth, MapHeight: mapHeight}
+	s := settings.GetSettings(world)
+
+	// Register the input source
+	ie := world.Create(input.InputRes)
+	*input.InputRes.Get(world.Entry(ie)) = input.Input{Source: input.Ebiten{}}
+
+	// Register the camera, starting over the Atreides start
+	ce := world.Create(camera.CameraRes)
+	start := m.Start(components.FactionAtreides)
+	camera.CameraRes.Get(world.Entry(ce)).CenterOn(s, start.X, start.Y)
+
+	// Create minimap
+	mme := world.Create(components.MinimapRes)
+	*components.MinimapRes.Get(world.Entry(mme)) = components.Minimap{Width: w / 5, Height: h / 5, X: w - w/5 - 10, Y: 10}
+
+	// The whole map is in sight
+	fe := world.Create(fog.FogRes)
+	fogRes := fog.NewFog(s, 16)
+	for _, row := range fogRes.Grid {
+		for x := range row {
+			row[x] = fog.Visible
+		}
+	}
+	*fog.FogRes.Get(world.Entry(fe)) = *fogRes
+
+	// Create terrain
+	te := world.Create(terrain.TerrainRes)
+	ter := terrain.NewTerrain(s, mapfile.TileSize)
+	ter.Grid = m.Grid()
+	*terrain.TerrainRes.Get(world.Entry(te)) = *ter
+
+	// Register the unit and building definitions
+	de := world.Create(defs.DefsRes)
+	*defs.DefsRes.Get(world.Entry(de)) = *d
+
+	ecs.AddSystem(camera.Update)
+	ecs.AddSystem(systems.UpdateMinimap)
+
+	ecs.AddRenderer(systems.LayerTerrain, systems.DrawTerrain)
+	ecs.AddRenderer(systems.LayerSpice, systems.DrawSpice)
+	ecs.AddRenderer(systems.LayerBuildings, systems.DrawBuildings)
+	ecs.AddRenderer(systems.LayerUnits, systems.DrawUnits)
+	ecs.AddRenderer(systems.LayerMinimap, systems.DrawMinimap)
+	return ecs
+}
+
+// rebuild recreates the spice fields, units and buildings of the world from the map.
+func (e *Editor) rebuild() {
+	world := e.ecs.World
+	var old []donburi.Entity
+	qMapEntities.Each(world, func(entry *donburi.Entry) {
+		old = append(old, entry.Entity())
+	})
+	for _, entity := range old {
+		world.Remove(entity)
+	}
+
+	for _, sp := range e.m.Spice {
+		factory.CreateSpiceField(world, sp.X, sp.Y, sp.Size, sp.Amount)
+	}
+	for _, b := range e.m.Buildings {
+		btype, _ := defs.BuildingType(b.Type)
+		factory.CreateBuilding(world, b.Faction, btype, b.X, b.Y)
+	}
+	for _, u := range e.m.Units {
+		utype, _ := defs.UnitType(u.Type)
+		factory.CreateUnit(world, u.Faction, utype, u.X, u.Y)
+	}
+}
+
+func (e *Editor) Update() error {
+	e.ecs.Update()
+
+	in := input.GetInput(e.ecs.World)
+	for key, tool := range map[ebiten.Key]Tool{ebiten.Key1: ToolSpice, ebiten.Key2: ToolStart, ebiten.Key3: ToolUnit, ebiten.Key4: ToolBuilding} {
+		if in.IsKeyJustPressed(key) {
+			e.tool = tool
+		}
+	}
+	if in.IsKeyJustPressed(ebiten.KeyTab) {
+		e.faction = (e.faction + 1) % components.Faction(len(factionNames))
+	}
+	if in.IsKeyJustPressed(ebiten.KeyT) {
+		switch e.tool {
+		case ToolUnit:
+			e.unit = (e.unit + 1) % len(e.defs.Units)
+		case ToolBuilding:
+			e.building = (e.building + 1) % len(e.defs.Buildings)
+		}
+	}
+	if in.IsKeyJustPressed(ebiten.KeyF5) {
+		e.save()
+	}
+
+	// The minimap handles clicks on itself
+	mx, my := in.CursorPosition()
+	minimapEntry, _ := systems.MinimapQuery.First(e.ecs.World)
+	minimap := components.MinimapRes.Get(minimapEntry)
+	if mx >= minimap.X && mx < minimap.X+minimap.Width && my >= minimap.Y && my < minimap.Y+minimap.Height {
+		return nil
+	}
+
+	cameraEntry, _ := camera.CameraQuery.First(e.ecs.World)
+	x, y := camera.CameraRes.Get(cameraEntry).ScreenToWorld(float64(mx), float64(my))
+	changed := false
+	if in.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		changed = e.place(x, y)
+	}
+	if in.IsMouseButtonJustPressed(ebiten.MouseButtonRight) {
+		changed = e.remove(x, y) || changed
+	}
+	if _, dy := in.Wheel(); dy != 0 && in.IsKeyPressed(ebiten.KeyShift) {
+		changed = e.resizeSpice(x, y, dy) || changed
+	}
+	if changed {
+		e.rebuild()
+	}
+	return nil
+}
+
+// place adds what the current tool places at a world position, centered on it. Nothing is placed off the map,
+// and what is placed at its edge is moved onto it.
+func (e *Editor) place(x, y float64) bool {
+	if !e.m.Contains(x, y) {
+		return false
+	}
+	switch e.tool {
+	case ToolSpice:
+		e.m.Spice = append(e.m.Spice, mapfile.Spice{X: math.Max(x-factory.SpiceSize/2, 0), Y: math.Max(y-factory.SpiceSize/2, 0), Size: factory.SpiceSize, Amount: spiceAmount})
+	case ToolStart:
+		start := e.m.Start(e.faction)
+		start.X, start.Y = x, y
+	case ToolUnit:
+		def := e.defs.Units[e.unit]
+		e.m.Units = append(e.m.Units, mapfile.Entity{Type: def.ID, Faction: e.faction, X: math.Max(x-def.Size/2, 0), Y: math.Max(y-def.Size/2, 0)})
+	case ToolBuilding:
+		// Buildings are aligned to the terrain tiles, like when they are placed in a match
+		def := e.defs.Buildings[e.building]
+		ts := float64(mapfile.TileSize)
+		bx := math.Max(math.Floor((x-def.Width/2)/ts+0.5)*ts, 0)
+		by := math.Max(math.Floor((y-def.Height/2)/ts+0.5)*ts, 0)
+		e.m.Buildings = append(e.m.Buildings, mapfile.Entity{Type: def.ID, Faction: e.faction, X: bx, Y: by})
+	}
+	return true
+}
+
+// remove deletes the topmost unit, building or spice field at a world position.
+func (e *Editor) remove(x, y float64) bool {
+	for i := len(e.m.Units) - 1; i >= 0; i-- {
+		u := e.m.Units[i]
+		utype, _ := defs.UnitType(u.Type)
+		size := e.defs.Unit(utype).Size
+		if inRect(x, y, u.X, u.Y, size, size) {
+			e.m.Units = append(e.m.Units[:i], e.m.Units[i+1:]...)
+			return true
+		}
+	}
+	for i := len(e.m.Buildings) - 1; i >= 0; i-- {
+		b := e.m.Buildings[i]
+		btype, _ := defs.BuildingType(b.Type)
+		def := e.defs.Building(btype)
+		if inRect(x, y, b.X, b.Y, def.Width, def.Height) {
+			e.m.Buildings = append(e.m.Buildings[:i], e.m.Buildings[i+1:]...)
+			return true
+		}
+	}
+	if i := e.spiceAt(x, y); i >= 0 {
+		e.m.Spice = append(e.m.Spice[:i], e.m.Spice[i+1:]...)
+		return true
+	}
+	return false
+}
+
+// resizeSpice grows or shrinks the spice field at a world position around its center, by a step per turn of the wheel.
+func (e *Editor) resizeSpice(x, y, wheel float64) bool {
+	i := e.spiceAt(x, y)
+	if i < 0 {
+		return false
+	}
+	sp := &e.m.Spice[i]
+	size := sp.Size + spiceStep
+	if wheel < 0 {
+		size = sp.Size - spiceStep
+	}
+	size = math.Max(minSpice, math.Min(maxSpice, size))
+	sp.X = math.Max(sp.X-(size-sp.Size)/2, 0)
+	sp.Y = math.Max(sp.Y-(size-sp.Size)/2, 0)
+	sp.Size = size
+	return true
+}
+
+// spiceAt returns the index of the topmost spice field at a world position, or -1 if there is none.
+func (e *Editor) spiceAt(x, y float64) int {
+	for i := len(e.m.Spice) - 1; i >= 0; i-- {
+		sp := e.m.Spice[i]
+		if inRect(x, y, sp.X, sp.Y, sp.Size, sp.Size) {
+			return i
+		}
+	}
+	return -1
+}
+
+// inRect reports whether (x, y) lies in the rectangle with its top-left corner at (rx, ry).
+func inRect(x, y, rx, ry, w, h float64) bool {
+	return x >= rx && x < rx+w && y >= ry && y < ry+h
+}
+
+// save writes the map to the editor's file.
+func (e *Editor) save() {
+	if err := e.m.Save(e.path); err != nil {
+		e.status = fmt.Sprintf("save failed: %v", err)
+	} else {
+		e.status = "saved to " + e.path
+	}
+	log.Print(e.status)
+}
+
+func (e *Editor) Draw(screen *ebiten.Image) {
+	e.ecs.DrawLayer(systems.LayerTerrain, screen)
+	e.ecs.DrawLayer(systems.LayerSpice, screen)
+	e.ecs.DrawLayer(systems.LayerBuildings, screen)
+	e.ecs.DrawLayer(systems.LayerUnits, screen)
+
+	// Mark every start position with a cross in its faction's color
+	cameraEntry, _ := camera.CameraQuery.First(e.ecs.World)
+	cam := camera.CameraRes.Get(cameraEntry)
+	for _, start := range e.m.Starts {
+		x, y := cam.WorldToScreen(start.X, start.Y)
+		sx, sy := float32(x), float32(y)
+		c := factory.FactionColor(start.Faction)
+		vector.StrokeLine(screen, sx-10, sy-10, sx+10, sy+10, 3, c, false)
+		vector.StrokeLine(screen, sx-10, sy+10, sx+10, sy-10, 3, c, false)
+		text.Draw(screen, factionNames[start.Faction]+" start", basicfont.Face7x13, int(sx)+14, int(sy)+4, color.White)
+	}
+
+	e.ecs.DrawLayer(systems.LayerMinimap, screen)
+
+	// Show what a click places and how to change it
+	placing := toolNames[e.tool]
+	switch e.tool {
+	case ToolUnit:
+		placing = e.defs.Units[e.unit].Name
+	case ToolBuilding:
+		placing = e.defs.Buildings[e.building].Name
+	}
+	lines := []string{
+		fmt.Sprintf("Placing: %s   Faction: %s", placing, factionNames[e.faction]),
+		"1 spice  2 start  3 unit  4 building  T type  Tab faction",
+		"Left-click place  Right-click remove  Shift+Wheel resize spice  Wheel zoom  F5 save",
+		e.status,
+	}
+	vector.DrawFilledRect(screen, 5, 5, 600, float32(len(lines))*16+8, color.RGBA{A: 160}, false)
+	for i, line := range lines {
+		text.Draw(screen, line, basicfont.Face7x13, 10, 20+i*16, color.White)
+	}
+}
+
+func (e *Editor) Layout(outsideW, outsideH int) (int, int) {
+	s := settings.GetSettings(e.ecs.World)
+	return s.ScreenWidth, s.ScreenHeight
+}
@@ -3,124 +3,116 @@ package factory
 import (
 	"fmt"
 	"image/color"
-	"math/rand"
 
+	"github.com/gfeyer/ebit/internal/assets"
 	"github.com/gfeyer/ebit/internal/components"
+	"github.com/gfeyer/ebit/internal/defs"
+	"github.com/gfeyer/ebit/internal/rng"
+	"github.com/gfeyer/ebit/internal/settings"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/text"
 	"github.com/yohamta/donburi"
 	"golang.org/x/image/font/basicfont"
 )
 
-func CreateHarvester(w donburi.World, x, y float64) {
-	e := w.Create(components.Position, components.Sprite, components.UnitRes, components.SelectableRes, components.TargetRes, components.Velocity, components.HarvesterRes, components.HealthRes)
-	entry := w.Entry(e)
-
-	// Harvester is a blue square
-	img := ebiten.NewImage(16, 16)
-	img.Fill(color.RGBA{R: 0, G: 0, B: 255, A: 255})
-
-	*components.Position.Get(entry) = components.Pos{X: x, Y: y}
-	*components.Sprite.Get(entry) = img
-	*components.UnitRes.Get(entry) = components.Unit{Type: components.Harvester}
-	*components.SelectableRes.Get(entry) = components.Selectable{Selected: false}
-	*components.Velocity.Get(entry) = components.Vel{}
-	*components.HarvesterRes.Get(entry) = components.HarvesterData{Capacity: 100}
-	*components.HealthRes.Get(entry) = components.Health{Current: 100, Max: 100}
+// FactionColor returns the color used to draw a faction's units and markers.
+func FactionColor(faction components.Faction) color.RGBA {
+	switch faction {
+	case components.FactionHarkonnen:
+		return color.RGBA{R: 200, G: 0, B: 0, A: 255}
+	default:
+		return color.RGBA{R: 0, G: 0, B: 255, A: 255}
+	}
 }
 
-func CreateTrike(w donburi.World, x, y float64) {
-	e := w.Create(components.Position, components.Sprite, components.UnitRes, components.SelectableRes, components.TargetRes, components.Velocity, components.HealthRes)
-	entry := w.Entry(e)
+// CreateHarvester creates a harvester for a faction.
+func CreateHarvester(w donburi.World, faction components.Faction, x, y float64) donburi.Entity {
+	return CreateUnit(w, faction, components.Harvester, x, y)
+}
 
-	// Trike is a blue triangle
-	img := ebiten.NewImage(24, 24)
-	r, g, b, a := color.RGBA{R: 0, G: 0, B: 255, A: 255}.RGBA()
-	triangle := []ebiten.Vertex{
-		{DstX: 12, DstY: 2, SrcX: 0, SrcY: 0, ColorR: float32(r) / 0xffff, ColorG: float32(g) / 0xffff, ColorB: float32(b) / 0xffff, ColorA: float32(a) / 0xffff},
-		{DstX: 2, DstY: 22, SrcX: 0, SrcY: 0, ColorR: float32(r) / 0xffff, ColorG: float32(g) / 0xffff, ColorB: float32(b) / 0xffff, ColorA: float32(a) / 0xffff},
-		{DstX: 22, DstY: 22, SrcX: 0, SrcY: 0, ColorR: float32(r) / 0xffff, ColorG: float32(g) / 0xffff, ColorB: float32(b) / 0xffff, ColorA: float32(a) / 0xffff},
-	}
-	whiteSubimage := ebiten.NewImage(1, 1)
-	whiteSubimage.Fill(color.White)
-	img.DrawTriangles(triangle, []uint16{0, 1, 2}, whiteSubimage, &ebiten.DrawTrianglesOptions{
-		FillRule: ebiten.FillAll,
-	})
+// CreateTrike creates a trike for a faction.
+func CreateTrike(w donburi.World, faction components.Faction, x, y float64) donburi.Entity {
+	return CreateUnit(w, faction, components.Trike, x, y)
+}
 
-	*components.Position.Get(entry) = components.Pos{X: x, Y: y}
-	*components.Sprite.Get(entry) = img
-	*components.UnitRes.Get(entry) = components.Unit{Type: components.Trike}
-	*components.SelectableRes.Get(entry) = components.Selectable{Selected: false}
-	*components.Velocity.Get(entry) = components.Vel{}
-	*components.HealthRes.Get(entry) = components.Health{Current: 50, Max: 50}
+// CreateQuad creates a quad for a faction.
+func CreateQuad(w donburi.World, faction components.Faction, x, y float64) donburi.Entity {
+	return CreateUnit(w, faction, components.Quad, x, y)
 }
 
-func CreateQuad(w donburi.World, x, y float64) {
-	e := w.Create(components.Position, components.Sprite, components.UnitRes, components.SelectableRes, components.TargetRes, components.Velocity, components.HealthRes)
-	entry := w.Entry(e)
+// SpiceSize is the length of the sides of a generated spice field, in pixels.
+const SpiceSize = 64
 
-	// Quad is a green square
-	img := ebiten.NewImage(16, 16)
-	img.Fill(color.RGBA{R: 0, G: 255, B: 0, A: 255})
+// RandomSpiceAmount draws the amount of spice in a generated spice field.
+func RandomSpiceAmount(w donburi.World) int {
+	return rng.GetRNG(w).Intn(2000) + 1000
+}
 
-	*components.Position.Get(entry) = components.Pos{X: x, Y: y}
-	*components.Sprite.Get(entry) = img
-	*components.UnitRes.Get(entry) = components.Unit{Type: components.Quad}
-	*components.SelectableRes.Get(entry) = components.Selectable{Selected: false}
-	*components.Velocity.Get(entry) = components.Vel{}
-	*components.HealthRes.Get(entry) = components.Health{Current: 80, Max: 80}
+// CreateSpice creates a generated spice field with a random amount of spice.
+func CreateSpice(w donburi.World, x, y float64) donburi.Entity {
+	return CreateSpiceField(w, x, y, SpiceSize, RandomSpiceAmount(w))
 }
 
-func CreateSpice(w donburi.World, x, y float64) {
-	e := w.Create(components.Position, components.Sprite, components.SpiceRes, components.Velocity, components.SelectableRes, components.SpiceAmountRes)
+// CreateSpiceField creates a square spice field with sides of the given size, holding the given amount of spice.
+func CreateSpiceField(w donburi.World, x, y, size float64, amount int) donburi.Entity {
+	e := w.Create(components.Position, components.SizeRes, components.SpiceRes, components.Velocity, components.SelectableRes, components.SpiceAmountRes)
 	entry := w.Entry(e)
 
 	// Spice is an orange square
-	img := ebiten.NewImage(64, 64)
-	img.Fill(color.RGBA{R: 210, G: 105, B: 30, A: 255})
+	addSprite(w, entry, func() *ebiten.Image {
+		img := ebiten.NewImage(int(size), int(size))
+		img.Fill(color.RGBA{R: 210, G: 105, B: 30, A: 255})
+		return img
+	})
 
 	*components.Position.Get(entry) = components.Pos{X: x, Y: y}
-	*components.Sprite.Get(entry) = img
+	*components.SizeRes.Get(entry) = components.Size{W: size, H: size}
 	*components.SpiceRes.Get(entry) = components.Spice{}
 	*components.Velocity.Get(entry) = components.Vel{}
 	*components.SelectableRes.Get(entry) = components.Selectable{Selected: false}
-	*components.SpiceAmountRes.Get(entry) = components.SpiceAmount{Amount: rand.Intn(2000) + 1000}
+	*components.SpiceAmountRes.Get(entry) = components.SpiceAmount{Amount: amount}
+	return e
 }
 
-func CreateBuildOption(w donburi.World, btype components.BuildingType, name string, cost int, width, height int) {
+// CreateBuildOption adds a building to the construction menu, with an icon of the given size.
+func CreateBuildOption(w donburi.World, def *defs.Building, width, height int) {
 	e := w.Create(components.BuildInfoRes)
 	entry := w.Entry(e)
 
-	icon := ebiten.NewImage(width, height)
-	bgColor := color.RGBA{R: 128, G: 128, B: 128, A: 255} // Gray background
-	icon.Fill(bgColor)
-
-	// Draw text on the icon
-	nameText := name
-	costText := fmt.Sprintf("Cost: %d", cost)
-
-	// Center the name text
-	nameBounds := text.BoundString(basicfont.Face7x13, nameText)
-	nameX := (width - nameBounds.Dx()) / 2
-	text.Draw(icon, nameText, basicfont.Face7x13, nameX, 15, color.White)
-
-	// Center the cost text
-	costBounds := text.BoundString(basicfont.Face7x13, costText)
-	costX := (width - costBounds.Dx()) / 2
-	text.Draw(icon, costText, basicfont.Face7x13, costX, 30, color.White)
-
 	*components.BuildInfoRes.Get(entry) = components.BuildInfo{
-		Type: btype,
-		Name: name,
-		Cost: cost,
-		Icon: icon,
+		Type:          def.Type,
+		Name:          def.Name,
+		Cost:          def.Cost,
+		BuildTime:     def.BuildTime,
+		Prerequisites: def.Prerequisites,
+	}
+	if settings.GetSettings(w).Headless {
+		return
 	}
+	components.BuildInfoRes.Get(entry).Icon = optionIcon(def.Name, def.Cost, width, height)
 }
 
-func CreateUnitOption(w donburi.World, utype components.UnitType, name string, cost int, requiredBuilding components.BuildingType, width, height int) {
+// CreateUnitOption adds a unit to the training menu of the building that trains it, with an icon of the given size.
+func CreateUnitOption(w donburi.World, def *defs.Unit, width, height int) {
 	e := w.Create(components.UnitInfoRes)
 	entry := w.Entry(e)
 
+	*components.UnitInfoRes.Get(entry) = components.UnitInfo{
+		Type:             def.Type,
+		Name:             def.Name,
+		Cost:             def.Cost,
+		BuildTime:        def.BuildTime,
+		RequiredBuilding: def.Building,
+		Prerequisites:    def.Prerequisites,
+	}
+	if settings.GetSettings(w).Headless {
+		return
+	}
+	components.UnitInfoRes.Get(entry).Icon = optionIcon(def.Name, def.Cost, width, height)
+}
+
+// optionIcon draws a menu icon showing a name and a cost.
+func optionIcon(name string, cost int, width, height int) *ebiten.Image {
 	icon := ebiten.NewImage(width, height)
 	bgColor := color.RGBA{R: 128, G: 128, B: 128, A: 255} // Gray background
 	icon.Fill(bgColor)
@@ -139,39 +131,175 @@ func CreateUnitOption(w donburi.World, utype components.UnitType, name string, c
 	costX := (width - costBounds.Dx()) / 2
 	text.Draw(icon, costText, basicfont.Face7x13, costX, 30, color.White)
 
-	*components.UnitInfoRes.Get(entry) = components.UnitInfo{
-		Type:             utype,
-		Name:             name,
-		Cost:             cost,
-		Icon:             icon,
-		RequiredBuilding: requiredBuilding,
-	}
+	return icon
+}
+
+// CreateBarracks creates a barracks for a faction.
+func CreateBarracks(w donburi.World, faction components.Faction, x, y float64) donburi.Entity {
+	return CreateBuilding(w, faction, components.BuildingBarracks, x, y)
+}
+
+// CreateRefinery creates a refinery for a faction.
+func CreateRefinery(w donburi.World, faction components.Faction, x, y float64) donburi.Entity {
+	return CreateBuilding(w, faction, components.BuildingRefinery, x, y)
 }
 
-func CreateBarracks(w donburi.World, x, y float64) {
-	e := w.Create(components.Position, components.Sprite, components.BarracksRes, components.SelectableRes)
+// CreateUnit creates a unit of the given type for a faction, as described by its definition.
+// Units with a weapon can attack; units with a capacity harvest spice.
+func CreateUnit(w donburi.World, faction components.Faction, utype components.UnitType, x, y float64) donburi.Entity {
+	def := defs.GetDefs(w).Unit(utype)
+	if def == nil {
+		return donburi.Null
+	}
+
+	types := []donburi.IComponentType{components.Position, components.SizeRes, components.UnitRes, components.SelectableRes, components.TargetRes, components.PathRes, components.Velocity, components.HealthRes, components.OwnerRes, components.VisionRes}
+	if def.Weapon != nil {
+		types = append(types, components.WeaponRes, components.AttackRes)
+	}
+	if def.Capacity > 0 {
+		types = append(types, components.HarvesterRes)
+	}
+	e := w.Create(types...)
 	entry := w.Entry(e)
 
-	// Barracks is a red square
-	img := ebiten.NewImage(64, 64)
-	img.Fill(color.RGBA{R: 255, G: 0, B: 0, A: 255})
+	addDefinedSprite(w, entry, def.Sprite, faction, int(def.Size), int(def.Size))
 
 	*components.Position.Get(entry) = components.Pos{X: x, Y: y}
-	*components.Sprite.Get(entry) = img
-	*components.BarracksRes.Get(entry) = components.Barracks{}
+	*components.SizeRes.Get(entry) = components.Size{W: def.Size, H: def.Size}
+	*components.OwnerRes.Get(entry) = components.Owner{Faction: faction}
+	*components.UnitRes.Get(entry) = components.Unit{Type: utype, Speed: def.Speed}
 	*components.SelectableRes.Get(entry) = components.Selectable{Selected: false}
+	*components.Velocity.Get(entry) = components.Vel{}
+	*components.HealthRes.Get(entry) = components.Health{Current: def.Health, Max: def.Health}
+	*components.VisionRes.Get(entry) = components.Vision{Radius: def.Vision}
+	if def.Weapon != nil {
+		*components.WeaponRes.Get(entry) = *def.Weapon
+	}
+	if def.Capacity > 0 {
+		*components.HarvesterRes.Get(entry) = components.HarvesterData{Capacity: def.Capacity}
+	}
+	return e
+}
+
+// BuildingSize returns the footprint of a building type in pixels.
+func BuildingSize(w donburi.World, btype components.BuildingType) (float64, float64) {
+	def := defs.GetDefs(w).Building(btype)
+	if def == nil {
+		return 0, 0
+	}
+	return def.Width, def.Height
 }
 
-func CreateRefinery(w donburi.World, x, y float64) {
-	e := w.Create(components.Position, components.Sprite, components.RefineryRes, components.SelectableRes)
+// CreateBuilding creates a building of the given type for a faction, as described by its definition.
+func CreateBuilding(w donburi.World, faction components.Faction, btype components.BuildingType, x, y float64) donburi.Entity {
+	def := defs.GetDefs(w).Building(btype)
+	if def == nil {
+		return donburi.Null
+	}
+
+	types := []donburi.IComponentType{components.Position, components.SizeRes, components.BuildingRes, components.SelectableRes, components.HealthRes, components.ProductionRes, components.OwnerRes, components.VisionRes}
+	switch btype {
+	case components.BuildingRefinery:
+		types = append(types, components.RefineryRes)
+	case components.BuildingBarracks:
+		types = append(types, components.BarracksRes)
+	}
+	if def.Weapon != nil {
+		types = append(types, components.WeaponRes, components.AttackRes)
+	}
+	e := w.Create(types...)
 	entry := w.Entry(e)
 
-	// Refinery is a gray square
-	img := ebiten.NewImage(64, 64)
-	img.Fill(color.RGBA{R: 128, G: 128, B: 128, A: 255})
+	addDefinedSprite(w, entry, def.Sprite, faction, int(def.Width), int(def.Height))
 
 	*components.Position.Get(entry) = components.Pos{X: x, Y: y}
-	*components.Sprite.Get(entry) = img
-	*components.RefineryRes.Get(entry) = components.Refinery{}
+	*components.SizeRes.Get(entry) = components.Size{W: def.Width, H: def.Height}
+	*components.BuildingRes.Get(entry) = components.Building{Type: btype}
+	*components.OwnerRes.Get(entry) = components.Owner{Faction: faction}
 	*components.SelectableRes.Get(entry) = components.Selectable{Selected: false}
+	*components.HealthRes.Get(entry) = components.Health{Current: def.Health, Max: def.Health}
+	*components.VisionRes.Get(entry) = components.Vision{Radius: def.Vision}
+	if def.Weapon != nil {
+		*components.WeaponRes.Get(entry) = *def.Weapon
+	}
+	return e
+}
+
+// BuildingTypeOf returns the type of a building entity, or false if the entity is not a building.
+func BuildingTypeOf(entry *donburi.Entry) (components.BuildingType, bool) {
+	if !entry.HasComponent(components.BuildingRes) {
+		return 0, false
+	}
+	return components.BuildingRes.Get(entry).Type, true
+}
+
+// addSprite gives an entity the sprite returned by draw. Headless worlds have no sprites, so draw is not called.
+func addSprite(w donburi.World, entry *donburi.Entry, draw func() *ebiten.Image) {
+	if settings.GetSettings(w).Headless {
+		return
+	}
+	entry.AddComponent(components.Sprite)
+	*components.Sprite.Get(entry) = draw()
+}
+
+// addDefinedSprite gives an entity the sprite its definition describes, scaled to width by height. The frames come
+// from the sprite sheets when the definition names an asset that exists; otherwise the definition's shape is drawn.
+// Sprites with several frames are directional.
+func addDefinedSprite(w donburi.World, entry *donburi.Entry, sprite defs.Sprite, faction components.Faction, width, height int) {
+	if settings.GetSettings(w).Headless {
+		return
+	}
+	frames := assets.Frames(sprite.Asset, FactionColor(faction), width, height)
+	if frames == nil {
+		addSprite(w, entry, func() *ebiten.Image {
+			return drawSprite(sprite, faction, width, height)
+		})
+		return
+	}
+	addSprite(w, entry, func() *ebiten.Image {
+		return frames[0]
+	})
+	if len(frames) > 1 {
+		entry.AddComponent(components.FramesRes)
+		*components.FramesRes.Get(entry) = components.Frames{Images: frames}
+	}
+}
+
+// drawSprite draws the shape a sprite definition describes, filling a width by height image.
+func drawSprite(sprite defs.Sprite, faction components.Faction, width, height int) *ebiten.Image {
+	img := ebiten.NewImage(width, height)
+	c := spriteColor(sprite, faction)
+	if sprite.Shape != "triangle" {
+		img.Fill(c)
+		return img
+	}
+
+	// A triangle pointing up, inset by a pixel or two from the edges
+	r, g, b, a := c.RGBA()
+	cr, cg, cb, ca := float32(r)/0xffff, float32(g)/0xffff, float32(b)/0xffff, float32(a)/0xffff
+	fw, fh := float32(width), float32(height)
+	triangle := []ebiten.Vertex{
+		{DstX: fw / 2, DstY: fh / 12, ColorR: cr, ColorG: cg, ColorB: cb, ColorA: ca},
+		{DstX: fw / 12, DstY: fh * 11 / 12, ColorR: cr, ColorG: cg, ColorB: cb, ColorA: ca},
+		{DstX: fw * 11 / 12, DstY: fh * 11 / 12, ColorR: cr, ColorG: cg, ColorB: cb, ColorA: ca},
+	}
+	whiteSubimage := ebiten.NewImage(1, 1)
+	whiteSubimage.Fill(color.White)
+	img.DrawTriangles(triangle, []uint16{0, 1, 2}, whiteSubimage, &ebiten.DrawTrianglesOptions{
+		FillRule: ebiten.FillAll,
+	})
+	return img
+}
+
+// spriteColor returns the color of a sprite: its faction's color, or the "#rrggbb" color it names.
+// Colors that can't be parsed are drawn magenta so they stand out.
+func spriteColor(sprite defs.Sprite, faction components.Faction) color.RGBA {
+	if sprite.Color == "faction" {
+		return FactionColor(faction)
+	}
+	var r, g, b uint8
+	if _, err := fmt.Sscanf(sprite.Color, "#%02x%02x%02x", &r, &g, &b); err != nil {
+		return color.RGBA{R: 255, B: 255, A: 255}
+	}
+	return color.RGBA{R: r, G: g, B: b, A: 255}
 }
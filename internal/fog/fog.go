@@ -1,6 +1,8 @@
 package fog
 
 import (
+	"fmt"
+
 	"github.com/gfeyer/ebit/internal/settings"
 	"github.com/yohamta/donburi"
 	"github.com/yohamta/donburi/filter"
@@ -14,12 +16,47 @@ const (
 	Visible                        // Currently visible by a unit
 )
 
+// Mode selects how the fog of war is shown. The grid always tracks what is in sight right now; the mode only
+// changes what State reports, so switching modes loses nothing.
+type Mode int
+
+const (
+	Dynamic Mode = iota // Tiles are shrouded again when no unit sees them anymore, and enemy units under the shroud are hidden
+	Classic             // Tiles stay revealed once they have been seen, as in the original game
+	Off                 // The whole map is revealed, for testing
+)
+
+var modeNames = [...]string{Dynamic: "dynamic", Classic: "classic", Off: "off"}
+
+func (m Mode) String() string {
+	if m < 0 || int(m) >= len(modeNames) {
+		return fmt.Sprintf("Mode(%d)", int(m))
+	}
+	return modeNames[m]
+}
+
+// ParseMode returns the mode with the given name: "dynamic", "classic" or "off".
+func ParseMode(name string) (Mode, error) {
+	for m, n := range modeNames {
+		if n == name {
+			return Mode(m), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown fog mode %q", name)
+}
+
 // Fog is a resource that holds the state of the fog of war.
 type Fog struct {
 	Grid     [][]VisibilityState
 	TileSize int
 	Width    int
 	Height   int
+	Mode     Mode
+	// viewers counts, for every tile, how many of the local player's units and buildings see it. It isn't saved;
+	// a fog without it is Reset and its viewers revealed again.
+	viewers [][]int
+	// version counts the changes to Grid made through the fog's methods.
+	version uint64
 }
 
 var FogRes = donburi.NewComponentType[Fog]()
@@ -47,3 +84,79 @@ func GetFog(w donburi.World) *Fog {
 	entry, _ := donburi.NewQuery(filter.Contains(FogRes)).First(w)
 	return FogRes.Get(entry)
 }
+
+// State returns how the tile at (x, y) is shown in the fog's mode.
+func (f *Fog) State(x, y int) VisibilityState {
+	switch {
+	case f.Mode == Off:
+		return Visible
+	case f.Mode == Classic && f.Grid[y][x] == Shroud:
+		return Visible
+	}
+	return f.Grid[y][x]
+}
+
+// SetMode switches the fog to another mode.
+func (f *Fog) SetMode(m Mode) {
+	f.Mode = m
+	f.version++
+}
+
+// Version returns a number that changes whenever a tile changes state through Reset, Reveal or Conceal, or the mode
+// changes, so renderers can tell when an image of the fog is out of date.
+func (f *Fog) Version() uint64 {
+	return f.version
+}
+
+// Tracking reports whether the fog is counting the viewers of its tiles. It isn't after being loaded from a save file.
+func (f *Fog) Tracking() bool {
+	return len(f.viewers) == f.Height
+}
+
+// Reset forgets every viewer. Visible tiles become shrouded until a viewer reveals them again.
+func (f *Fog) Reset() {
+	f.version++
+	f.viewers = make([][]int, f.Height)
+	for y := range f.viewers {
+		f.viewers[y] = make([]int, f.Width)
+		for x := range f.Grid[y] {
+			if f.Grid[y][x] == Visible {
+				f.Grid[y][x] = Shroud
+			}
+		}
+	}
+}
+
+// Reveal adds a viewer that sees every tile within radius tiles of tile (tx, ty), making those tiles visible.
+func (f *Fog) Reveal(tx, ty, radius int) {
+	f.eachInRadius(tx, ty, radius, func(x, y int) {
+		f.viewers[y][x]++
+		if f.Grid[y][x] != Visible {
+			f.Grid[y][x] = Visible
+			f.version++
+		}
+	})
+}
+
+// Conceal removes a viewer added by Reveal with the same arguments. Tiles no other viewer sees become shrouded.
+func (f *Fog) Conceal(tx, ty, radius int) {
+	f.eachInRadius(tx, ty, radius, func(x, y int) {
+		f.viewers[y][x]--
+		if f.viewers[y][x] == 0 {
+			f.Grid[y][x] = Shroud
+			f.version++
+		}
+	})
+}
+
+// eachInRadius calls fn for every tile on the map within radius tiles of tile (tx, ty).
+func (f *Fog) eachInRadius(tx, ty, radius int, fn func(x, y int)) {
+	for y := max(ty-radius, 0); y <= min(ty+radius, f.Height-1); y++ {
+		for x := max(tx-radius, 0); x <= min(tx+radius, f.Width-1); x++ {
+			dx, dy := x-tx, y-ty
+			if dx*dx+dy*dy <= radius*radius {
+				fn(x, y)
+			}
+		}
+	}
+}
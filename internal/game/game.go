@@ -1,15 +1,27 @@
 package game
 
 import (
-	"image/color"
-	"math/rand"
+	"errors"
+	"fmt"
+	"log"
 
 	"github.com/gfeyer/ebit/internal/camera"
+	"github.com/gfeyer/ebit/internal/command"
 	"github.com/gfeyer/ebit/internal/components"
+	"github.com/gfeyer/ebit/internal/defs"
 	"github.com/gfeyer/ebit/internal/factory"
 	"github.com/gfeyer/ebit/internal/fog"
+	"github.com/gfeyer/ebit/internal/input"
+	"github.com/gfeyer/ebit/internal/mapfile"
+	"github.com/gfeyer/ebit/internal/netplay"
+	"github.com/gfeyer/ebit/internal/netplay/client"
+	"github.com/gfeyer/ebit/internal/replay"
+	"github.com/gfeyer/ebit/internal/rng"
+	"github.com/gfeyer/ebit/internal/savegame"
 	"github.com/gfeyer/ebit/internal/settings"
+	"github.com/gfeyer/ebit/internal/spatial"
 	"github.com/gfeyer/ebit/internal/systems"
+	"github.com/gfeyer/ebit/internal/terrain"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/yohamta/donburi"
 	"github.com/yohamta/donburi/ecs"
@@ -17,11 +29,228 @@ import (
 
 type Game struct {
 	ecs *ecs.ECS
+
+	// recording collects the commands of the match while it is being recorded.
+	recording *replay.Replay
+	// playback feeds the commands of a replay while one is being played.
+	playback *replay.Playback
+	// paused stops a replay from advancing.
+	paused bool
+	// playbackDone is set once every command of the replay has been applied.
+	playbackDone bool
+
+	// net is the connection to the relay in a network match.
+	net *client.Client
+	// sentTurns is the number of turns whose start has been announced to the relay with the local player's commands.
+	sentTurns int
+	// outgoing collects the local player's commands until they are sent at the start of the next turn.
+	outgoing []command.Command
+
+	// mapFile is the designed map the match is played on, or nil if the map was generated.
+	mapFile *mapfile.Map
+}
+
+// fastForwardSpeed is the number of ticks simulated per frame while fast-forwarding a replay.
+const fastForwardSpeed = 8
+
+// saveFile is the file the game is saved to and loaded from.
+const saveFile = "dune.sav"
+
+// NewGame creates a new match played in a window, on the map m or, if m is nil, on a randomly generated map.
+// Everything random in the match is drawn from a generator seeded with seed, so the same seed, map and inputs
+// always play out the same way.
+func NewGame(w, h int, seed uint64, m *mapfile.Map) *Game {
+	return newGame(settings.Settings{ScreenWidth: w, ScreenHeight: h}, seed, input.Ebiten{}, components.FactionAtreides, true, m)
+}
+
+// NewHeadlessGame creates a new match that runs without a window, for tests and server processes.
+// No sprites are created and nothing is drawn; input is read from src and the match only advances when Step is called.
+func NewHeadlessGame(w, h int, seed uint64, src input.Source) *Game {
+	return newGame(settings.Settings{ScreenWidth: w, ScreenHeight: h, Headless: true}, seed, src, components.FactionAtreides, true, nil)
+}
+
+// NewNetworkGame creates a match against other players connected to the same relay. Every player runs the same
+// simulation; the commands of all players are exchanged through the relay and applied in lockstep.
+// The match is refused if the definitions this machine loaded aren't the ones the match is played with.
+func NewNetworkGame(w, h int, c *client.Client) (*Game, error) {
+	g := newGame(settings.Settings{ScreenWidth: w, ScreenHeight: h}, c.Welcome.Seed, input.Ebiten{}, c.Faction(), false, nil)
+	if hash := defs.GetDefs(g.ecs.World).Hash(); hash != c.Welcome.Defs {
+		return nil, fmt.Errorf("the match uses definitions %q, not the local %q", c.Welcome.Defs, hash)
+	}
+	g.net = c
+	// Local commands are only applied once they come back from the relay together with everyone else's.
+	command.GetBuffer(g.ecs.World).HoldLocal = true
+	return g, nil
+}
+
+// NewReplayGame creates a game that plays back a recorded match. Player input and the AI can't issue commands;
+// the camera moves freely, P pauses and holding Tab fast-forwards. A replay recorded with other unit and building
+// definitions than the loaded ones is refused, as it would play out differently.
+func NewReplayGame(r *replay.Replay) (*Game, error) {
+	g := NewGame(r.ScreenWidth, r.ScreenHeight, r.Seed, r.Map)
+	if hash := defs.GetDefs(g.ecs.World).Hash(); hash != r.Defs {
+		return nil, fmt.Errorf("the replay was recorded with definitions %q, not the local %q", r.Defs, hash)
+	}
+	g.Play(r)
+	return g, nil
+}
+
+// Play makes the match a playback of a replay recorded with the same seed and screen size.
+// The match must not have advanced yet.
+func (g *Game) Play(r *replay.Replay) {
+	g.playback = replay.NewPlayback(r)
+	// Every command comes from the replay, including those the AI gave, so whatever is issued now is dropped.
+	command.GetBuffer(g.ecs.World).HoldLocal = true
+}
+
+// StartRecording records the commands of the match from now on. The match must not have advanced yet.
+func (g *Game) StartRecording() {
+	s := settings.GetSettings(g.ecs.World)
+	g.recording = replay.New(rng.GetRNG(g.ecs.World).Seed, s.ScreenWidth, s.ScreenHeight, g.mapFile, defs.GetDefs(g.ecs.World).Hash())
+}
+
+// SaveRecording writes the recorded replay to a file.
+func (g *Game) SaveRecording(path string) error {
+	if g.recording == nil {
+		return errors.New("the game is not being recorded")
+	}
+	return g.recording.Save(path)
+}
+
+// newGame creates a match on a map, or on a randomly generated one if m is nil. This machine's player commands
+// the local faction. With ai set, the Harkonnen are run by the computer; the local player must then command the Atreides.
+func newGame(config settings.Settings, seed uint64, src input.Source, local components.Faction, ai bool, m *mapfile.Map) *Game {
+	if m != nil {
+		config.MapWidth, config.MapHeight = m.Width*mapfile.TileSize, m.Height*mapfile.TileSize
+	}
+	ecs := newECS(config, loadDefs(), seed, src)
+	world := ecs.World
+	designed := m
+	if m == nil {
+		m = randomMap(world)
+	}
+
+	// Create one player per faction
+	pe := world.Create(components.PlayerRes, components.ConstructionRes)
+	pentry := world.Entry(pe)
+	*components.PlayerRes.Get(pentry) = components.Player{Faction: components.FactionAtreides, Money: m.Start(components.FactionAtreides).Money, Local: local == components.FactionAtreides}
+
+	// The Harkonnen are run by the computer unless another player commands them
+	var ee donburi.Entity
+	if ai {
+		ee = world.Create(components.PlayerRes, components.ConstructionRes, components.AIRes)
+		*components.AIRes.Get(world.Entry(ee)) = components.AI{ThinkTimer: 60}
+	} else {
+		ee = world.Create(components.PlayerRes, components.ConstructionRes)
+	}
+	eentry := world.Entry(ee)
+	*components.PlayerRes.Get(eentry) = components.Player{Faction: components.FactionHarkonnen, Money: m.Start(components.FactionHarkonnen).Money, Local: local == components.FactionHarkonnen}
+
+	// Center the camera on the local player's start
+	s := settings.GetSettings(world)
+	start := m.Start(local)
+	cameraEntry, _ := camera.CameraQuery.First(world)
+	camera.CameraRes.Get(cameraEntry).CenterOn(s, start.X, start.Y)
+
+	// Lay out the terrain, the spice and the units and buildings of the map
+	terrain.GetTerrain(world).Grid = m.Grid()
+	for _, sp := range m.Spice {
+		factory.CreateSpiceField(world, sp.X, sp.Y, sp.Size, sp.Amount)
+	}
+	for _, b := range m.Buildings {
+		btype, _ := defs.BuildingType(b.Type)
+		factory.CreateBuilding(world, b.Faction, btype, b.X, b.Y)
+	}
+	for _, u := range m.Units {
+		utype, _ := defs.UnitType(u.Type)
+		factory.CreateUnit(world, u.Faction, utype, u.X, u.Y)
+	}
+
+	return &Game{ecs: ecs, mapFile: designed}
+}
+
+// GenerateMap returns the map a match started with NewGame(w, h, seed, nil) is played on.
+func GenerateMap(w, h int, seed uint64) *mapfile.Map {
+	ecs := newECS(settings.Settings{ScreenWidth: w, ScreenHeight: h, Headless: true}, loadDefs(), seed, &input.Scripted{})
+	return randomMap(ecs.World)
+}
+
+// randomMap generates a map the size of the world's map: the player starts in the center, the enemy in the
+// top-left corner, each on a buildable plateau with a trike, a harvester and a refinery, and spice is spread
+// over the open sand.
+func randomMap(world donburi.World) *mapfile.Map {
+	r := rng.GetRNG(world)
+	s := settings.GetSettings(world)
+	ter := terrain.NewTerrain(s, mapfile.TileSize)
+	m := mapfile.New(ter.Width, ter.Height)
+
+	// Start positions: the player in the center of the map, the enemy in the top-left corner
+	centerX := float64(s.MapWidth) / 2
+	centerY := float64(s.MapHeight) / 2
+	enemyX := float64(s.MapWidth) / 6
+	enemyY := float64(s.MapHeight) / 6
+	m.Starts = []mapfile.Start{
+		{Faction: components.FactionAtreides, X: centerX, Y: centerY, Money: 1000},
+		{Faction: components.FactionHarkonnen, X: enemyX, Y: enemyY, Money: 1000},
+	}
+
+	// Generate terrain with a buildable plateau around each starting base
+	ter.Generate(r, [2]float64{centerX, centerY}, [2]float64{enemyX, enemyY})
+
+	// Spawn initial units for both factions
+	for _, start := range m.Starts {
+		m.Units = append(m.Units,
+			mapfile.Entity{Type: "trike", Faction: start.Faction, X: start.X + 50, Y: start.Y + 50},
+			mapfile.Entity{Type: "harvester", Faction: start.Faction, X: start.X, Y: start.Y - 50},
+		)
+		m.Buildings = append(m.Buildings,
+			mapfile.Entity{Type: "refinery", Faction: start.Faction, X: start.X - 50, Y: start.Y - 50},
+			mapfile.Entity{Type: "windtrap", Faction: start.Faction, X: start.X - 50, Y: start.Y + 30},
+			mapfile.Entity{Type: "yard", Faction: start.Faction, X: start.X + 40, Y: start.Y - 50},
+		)
+	}
+
+	// Spawn spice on open sand and mark the ground under it as spice-bearing
+	for placed, attempts := 0, 0; placed < 50 && attempts < 1000; attempts++ {
+		x := r.Float64() * float64(s.MapWidth)
+		y := r.Float64() * float64(s.MapHeight)
+		if tt := ter.At(x, y); tt != terrain.Sand && tt != terrain.Dunes {
+			continue
+		}
+		m.Spice = append(m.Spice, mapfile.Spice{X: x, Y: y, Size: factory.SpiceSize, Amount: factory.RandomSpiceAmount(world)})
+		ter.FillRect(x, y, factory.SpiceSize, factory.SpiceSize, terrain.SpiceSand)
+		placed++
+	}
+
+	m.SetGrid(ter.Grid)
+	return m
+}
+
+// DefsHash returns the hash of the unit and building definitions a new game loads, for joining network matches.
+func DefsHash() string {
+	return loadDefs().Hash()
 }
 
-func NewGame(w, h int) *Game {
+// loadDefs returns the unit and building definitions. An invalid definitions file is reported and ignored.
+func loadDefs() *defs.Defs {
+	d, err := defs.Load()
+	if err != nil {
+		log.Printf("%v; using the built-in definitions", err)
+		return defs.Builtin()
+	}
+	return d
+}
+
+// newECS creates a world with the resources, systems, renderers and build menu entries every game needs,
+// but without players, terrain features, units or spice. Headless worlds get no renderers.
+func newECS(config settings.Settings, d *defs.Defs, seed uint64, src input.Source) *ecs.ECS {
 	world := donburi.NewWorld()
 	ecs := ecs.NewECS(world)
+	w, h := config.ScreenWidth, config.ScreenHeight
+	// Generated maps are four screens wide and high
+	if config.MapWidth == 0 || config.MapHeight == 0 {
+		config.MapWidth, config.MapHeight = w*4, h*4
+	}
 
 	// Register settings
 	e := world.Create(settings.SettingsRes)
@@ -29,16 +258,22 @@ func NewGame(w, h int) *Game {
 	*settings.SettingsRes.Get(entry) = settings.Settings{
 		ScreenWidth:  w,
 		ScreenHeight: h,
-		MapWidth:     w * 4,
-		MapHeight:    h * 4,
+		MapWidth:     config.MapWidth,
+		MapHeight:    config.MapHeight,
+		Headless:     config.Headless,
 	}
 
+	// Register the input source
+	ie := world.Create(input.InputRes)
+	ientry := world.Entry(ie)
+	*input.InputRes.Get(ientry) = input.Input{Source: src}
+
 	// Register camera
 	ce := world.Create(camera.CameraRes)
 	centry := world.Entry(ce)
 	*camera.CameraRes.Get(centry) = camera.Camera{
-		X: float64((w*4)/2 - w/2),
-		Y: float64((h*4)/2 - h/2),
+		X: float64(config.MapWidth/2 - w/2),
+		Y: float64(config.MapHeight/2 - h/2),
 	}
 
 	// Create minimap
@@ -61,74 +296,233 @@ func NewGame(w, h int) *Game {
 	plentry := world.Entry(ple)
 	*components.PlacementRes.Get(plentry) = components.Placement{}
 
-	// Create player
-	pe := world.Create(components.PlayerRes)
-	pentry := world.Entry(pe)
-	*components.PlayerRes.Get(pentry) = components.Player{Money: 1000}
+	// Create the command buffer
+	be := world.Create(command.BufferRes)
+	bentry := world.Entry(be)
+	*command.BufferRes.Get(bentry) = command.Buffer{}
+
+	// Register the unit and building definitions
+	dfe := world.Create(defs.DefsRes)
+	dfentry := world.Entry(dfe)
+	*defs.DefsRes.Get(dfentry) = *d
+
+	// Create the random number generator
+	re := world.Create(rng.RNGRes)
+	rentry := world.Entry(re)
+	*rng.RNGRes.Get(rentry) = *rng.New(seed)
 
 	// Create fog
+	s := settings.GetSettings(world)
 	fe := world.Create(fog.FogRes)
 	fentry := world.Entry(fe)
-	*fog.FogRes.Get(fentry) = *fog.NewFog(settings.GetSettings(world), 16)
+	*fog.FogRes.Get(fentry) = *fog.NewFog(s, 16)
+
+	// Create the spatial grid; it is filled every tick
+	ge := world.Create(spatial.GridRes)
+	*spatial.GridRes.Get(world.Entry(ge)) = *spatial.NewGrid(s.MapWidth, s.MapHeight)
+
+	// Create terrain
+	te := world.Create(terrain.TerrainRes)
+	tentry := world.Entry(te)
+	*terrain.TerrainRes.Get(tentry) = *terrain.NewTerrain(s, mapfile.TileSize)
 
-	// Register systems
+	// Register systems. They run in this order every tick; the simulation is only deterministic
+	// because the order never changes, so new systems must be added at a fixed position.
+	ecs.AddSystem(systems.UpdatePower)
 	ecs.AddSystem(systems.UpdateMovement)
+	ecs.AddSystem(systems.UpdateSpatial)
 	ecs.AddSystem(systems.ResolveCollisions)
 	ecs.AddSystem(systems.UpdateInput)
 	ecs.AddSystem(systems.UpdateBuildInput)
-	ecs.AddSystem(camera.Update)
-	ecs.AddSystem(systems.UpdateMinimap)
+	ecs.AddSystem(systems.UpdateAI)
+	ecs.AddSystem(systems.ProcessCommands)
+	ecs.AddSystem(systems.UpdateProduction)
 	ecs.AddSystem(systems.UpdateHarvester)
+	ecs.AddSystem(systems.UpdateCombat)
+	ecs.AddSystem(systems.UpdateTurrets)
+	ecs.AddSystem(systems.RemoveDead)
 	ecs.AddSystem(systems.UpdateFog)
 
 	// Register renderers
-	ecs.AddRenderer(systems.LayerSpice, systems.DrawSpice)
-	ecs.AddRenderer(systems.LayerBuildings, systems.DrawBuildings)
-	ecs.AddRenderer(systems.LayerUnits, systems.DrawUnits)
-	ecs.AddRenderer(systems.LayerUI, systems.DrawUI)
-	ecs.AddRenderer(systems.LayerMinimap, systems.DrawMinimap)
-	ecs.AddRenderer(systems.LayerBuildMenuUI, systems.DrawBuildMenu)
-	ecs.AddRenderer(systems.LayerPlacement, systems.DrawPlacement)
-	ecs.AddRenderer(systems.LayerFog, systems.DrawFog)
-
-	// Spawn initial units
-	s := settings.GetSettings(world)
-	centerX := float64(s.MapWidth) / 2
-	centerY := float64(s.MapHeight) / 2
-	factory.CreateTrike(world, centerX+50, centerY+50)
-	factory.CreateHarvester(world, centerX, centerY-50)
-	factory.CreateRefinery(world, centerX-50, centerY-50)
+	if !config.Headless {
+		ecs.AddRenderer(systems.LayerTerrain, systems.DrawTerrain)
+		ecs.AddRenderer(systems.LayerSpice, systems.DrawSpice)
+		ecs.AddRenderer(systems.LayerBuildings, systems.DrawBuildings)
+		ecs.AddRenderer(systems.LayerUnits, systems.DrawUnits)
+		ecs.AddRenderer(systems.LayerUnits, systems.DrawCombat)
+		ecs.AddRenderer(systems.LayerUI, systems.DrawUI)
+		ecs.AddRenderer(systems.LayerMinimap, systems.DrawMinimap)
+		ecs.AddRenderer(systems.LayerBuildMenuUI, systems.DrawBuildMenu)
+		ecs.AddRenderer(systems.LayerPlacement, systems.DrawPlacement)
+		ecs.AddRenderer(systems.LayerFog, systems.DrawFog)
+	}
 
-	// Create build options
+	// Create build options and unit options, in the order of the definitions
 	minimap := components.MinimapRes.Get(mmentry)
 	padding := 5
 	iconWidth := (minimap.Width - padding) / 2
 	iconHeight := 64
-	factory.CreateBuildOption(world, components.BuildingRefinery, "Refinery", 750, iconWidth, iconHeight)
-	factory.CreateBuildOption(world, components.BuildingBarracks, "Barracks", 250, iconWidth, iconHeight)
+	for _, def := range d.Buildings {
+		factory.CreateBuildOption(world, def, iconWidth, iconHeight)
+	}
+	for _, def := range d.Units {
+		factory.CreateUnitOption(world, def, iconWidth, iconHeight)
+	}
 
-	// Create unit options
-	factory.CreateUnitOption(world, components.Harvester, "Harvester", 500, components.BuildingRefinery, iconWidth, iconHeight)
-	factory.CreateUnitOption(world, components.Trike, "Trike", 350, components.BuildingBarracks, iconWidth, iconHeight)
-	factory.CreateUnitOption(world, components.Quad, "Quad", 800, components.BuildingBarracks, iconWidth, iconHeight)
+	return ecs
+}
 
-	// Spawn spice
-	for i := 0; i < 50; i++ {
-		x := rand.Float64() * float64(s.MapWidth)
-		y := rand.Float64() * float64(s.MapHeight)
-		factory.CreateSpice(world, x, y)
+// save writes the current game to the save file.
+func (g *Game) save() {
+	if err := savegame.Save(g.ecs.World, saveFile); err != nil {
+		log.Printf("save failed: %v", err)
+		return
 	}
+	log.Printf("game saved to %s", saveFile)
+}
 
-	return &Game{ecs: ecs}
+// load replaces the current game with the one in the save file. The current game is kept if loading fails.
+func (g *Game) load() {
+	loaded := newECS(*settings.GetSettings(g.ecs.World), defs.GetDefs(g.ecs.World), 0, input.GetInput(g.ecs.World))
+	if err := savegame.Load(loaded.World, saveFile); err != nil {
+		log.Printf("load failed: %v", err)
+		return
+	}
+	g.ecs = loaded
+	log.Printf("game loaded from %s", saveFile)
+}
+
+// updateView moves the camera and handles clicks on the minimap. It runs once per frame rather than as part of Step,
+// so the view keeps responding while a replay is paused and doesn't speed up while it is fast-forwarded.
+// Orders given on the minimap wait in the command buffer until the next tick is processed.
+func (g *Game) updateView() {
+	camera.Update(g.ecs)
+	systems.UpdateMinimap(g.ecs)
+}
+
+// SetFogMode changes how the fog of war is shown. The fog only affects what this machine draws, so the mode can
+// change at any time, even during a network match or a replay.
+func (g *Game) SetFogMode(m fog.Mode) {
+	fog.GetFog(g.ecs.World).SetMode(m)
 }
 
 func (g *Game) Update() error {
-	g.ecs.Update()
+	g.updateView()
+
+	// F7 cycles through the fog of war modes.
+	if input.GetInput(g.ecs.World).IsKeyJustPressed(ebiten.KeyF7) {
+		m := (fog.GetFog(g.ecs.World).Mode + 1) % (fog.Off + 1)
+		g.SetFogMode(m)
+		log.Printf("fog of war: %v", m)
+	}
+
+	if g.net != nil {
+		return g.updateNetwork()
+	}
+	if g.playback == nil {
+		g.Step()
+		return nil
+	}
+
+	in := input.GetInput(g.ecs.World)
+	if in.IsKeyJustPressed(ebiten.KeyP) {
+		g.paused = !g.paused
+	}
+	steps := 1
+	if in.IsKeyPressed(ebiten.KeyTab) {
+		steps = fastForwardSpeed
+	}
+	for i := 0; i < steps && !g.paused; i++ {
+		g.Step()
+	}
+	return nil
+}
+
+// updateNetwork advances a network match by one tick, unless the commands of the current turn haven't all arrived yet.
+// At the start of every turn the local player's commands from the previous turn are sent to the relay, to be
+// applied netplay.TurnDelay turns later.
+func (g *Game) updateNetwork() error {
+	if err := g.net.Err(); err != nil {
+		return fmt.Errorf("lost connection to the server: %w", err)
+	}
+
+	buffer := command.GetBuffer(g.ecs.World)
+	var incoming []command.Command
+	if buffer.Tick%netplay.TurnTicks == 0 {
+		turn := buffer.Tick / netplay.TurnTicks
+		if g.sentTurns == turn {
+			if err := g.net.SendTurn(turn+netplay.TurnDelay, g.outgoing); err != nil {
+				return fmt.Errorf("sending commands: %w", err)
+			}
+			g.outgoing = nil
+			g.sentTurns++
+		}
+		// The first turns have no commands; every later one waits for the relay.
+		if turn >= netplay.TurnDelay {
+			commands, ok := g.net.Turn(turn)
+			if !ok {
+				return nil
+			}
+			incoming = commands
+		}
+	}
+
+	buffer.Incoming = incoming
+	g.Step()
 	return nil
 }
 
+// Step advances the match by one tick. Ebiten calls it through Update; headless games call it directly.
+// In a network match the commands of the tick must already be in the command buffer.
+// The camera and the minimap are not part of the simulation and are left to Update.
+func (g *Game) Step() {
+	buffer := command.GetBuffer(g.ecs.World)
+
+	if g.playback != nil {
+		buffer.Incoming = g.playback.Commands(buffer.Tick)
+	} else {
+		// F5 saves the game, F9 loads the last save. A recording can't survive a load, so it stops.
+		in := input.GetInput(g.ecs.World)
+		if in.IsKeyJustPressed(ebiten.KeyF5) {
+			g.save()
+		}
+		if in.IsKeyJustPressed(ebiten.KeyF9) {
+			switch {
+			case g.recording != nil:
+				log.Printf("can't load a game while recording a replay")
+			case g.net != nil:
+				log.Printf("can't load a game in a network match")
+			default:
+				g.load()
+				buffer = command.GetBuffer(g.ecs.World)
+			}
+		}
+	}
+
+	g.ecs.Update()
+
+	if g.net != nil {
+		g.outgoing = append(g.outgoing, buffer.Outgoing...)
+	}
+
+	if g.recording != nil {
+		g.recording.Record(buffer.Tick-1, buffer.Applied)
+	}
+	// Stop at the end of the replay; unpausing continues the match without further commands.
+	if g.playback != nil && !g.playbackDone && g.playback.Done(buffer.Tick) {
+		log.Printf("replay finished at tick %d", buffer.Tick)
+		g.playbackDone = true
+		g.paused = true
+	}
+}
+
+// World returns the world the match is simulated in.
+func (g *Game) World() donburi.World {
+	return g.ecs.World
+}
+
 func (g *Game) Draw(screen *ebiten.Image) {
-	screen.Fill(color.RGBA{210, 180, 140, 255}) // sand color
+	g.ecs.DrawLayer(systems.LayerTerrain, screen)
 	g.ecs.DrawLayer(systems.LayerSpice, screen)
 	g.ecs.DrawLayer(systems.LayerBuildings, screen)
 	g.ecs.DrawLayer(systems.LayerUnits, screen)
@@ -0,0 +1,133 @@
+package game_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/gfeyer/ebit/internal/camera"
+	"github.com/gfeyer/ebit/internal/components"
+	"github.com/gfeyer/ebit/internal/game"
+	"github.com/gfeyer/ebit/internal/input"
+	"github.com/gfeyer/ebit/internal/savegame"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/yohamta/donburi"
+	"github.com/yohamta/donburi/filter"
+)
+
+const screenWidth, screenHeight = 1280, 720
+
+var qUnits = donburi.NewQuery(filter.Contains(components.Position, components.UnitRes, components.OwnerRes))
+
+// step advances a headless game by n ticks, ending the tick of the scripted input after each one.
+func step(g *game.Game, src *input.Scripted, n int) {
+	for i := 0; i < n; i++ {
+		g.Step()
+		src.EndTick()
+	}
+}
+
+// click presses and releases a mouse button at a screen position.
+func click(g *game.Game, src *input.Scripted, button ebiten.MouseButton, x, y int) {
+	src.MoveCursor(x, y)
+	src.PressButton(button)
+	step(g, src, 1)
+	src.ReleaseButton(button)
+	step(g, src, 1)
+}
+
+// localUnit returns the first unit of a type owned by the local player.
+func localUnit(t *testing.T, g *game.Game, utype components.UnitType) *donburi.Entry {
+	t.Helper()
+	var found *donburi.Entry
+	qUnits.Each(g.World(), func(entry *donburi.Entry) {
+		if found == nil && components.UnitRes.Get(entry).Type == utype && components.OwnerRes.Get(entry).Faction == components.FactionAtreides {
+			found = entry
+		}
+	})
+	if found == nil {
+		t.Fatalf("no local unit of type %v", utype)
+	}
+	return found
+}
+
+// toScreen returns the screen position of a world position.
+func toScreen(g *game.Game, x, y float64) (int, int) {
+	cameraEntry, _ := camera.CameraQuery.First(g.World())
+	sx, sy := camera.CameraRes.Get(cameraEntry).WorldToScreen(x, y)
+	return int(sx), int(sy)
+}
+
+// orderMove selects the local trike by clicking it and right-clicks dx, dy screen pixels away from it,
+// returning the trike and the world position it was ordered to.
+func orderMove(t *testing.T, g *game.Game, src *input.Scripted, dx, dy int) (*donburi.Entry, float64, float64) {
+	t.Helper()
+	trike := localUnit(t, g, components.Trike)
+	p := components.Position.Get(trike)
+	size := components.SizeRes.Get(trike)
+	sx, sy := toScreen(g, p.X+size.W/2, p.Y+size.H/2)
+	click(g, src, ebiten.MouseButtonLeft, sx, sy)
+	if !components.SelectableRes.Get(trike).Selected {
+		t.Fatal("clicking the trike didn't select it")
+	}
+	click(g, src, ebiten.MouseButtonRight, sx+dx, sy+dy)
+	target := components.TargetRes.Get(trike)
+	return trike, target.X, target.Y
+}
+
+func TestHeadlessRightClickMovesUnit(t *testing.T) {
+	src := &input.Scripted{}
+	g := game.NewHeadlessGame(screenWidth, screenHeight, 1, src)
+	step(g, src, 1)
+
+	trike := localUnit(t, g, components.Trike)
+	start := *components.Position.Get(trike)
+	_, tx, ty := orderMove(t, g, src, 160, 0)
+	if tx == start.X && ty == start.Y {
+		t.Fatal("right-clicking didn't give the trike a target")
+	}
+
+	step(g, src, 300)
+	p := components.Position.Get(trike)
+	before := math.Hypot(tx-start.X, ty-start.Y)
+	after := math.Hypot(tx-p.X, ty-p.Y)
+	if after >= before/2 {
+		t.Errorf("trike moved from %v to %v, still %.0f of %.0f pixels from its target (%.0f, %.0f)", start, *p, after, before, tx, ty)
+	}
+}
+
+// playScripted plays a match with the given seed for a number of ticks, ordering the local trike around on the way,
+// and returns the state it ends in.
+func playScripted(t *testing.T, seed uint64, ticks int) []byte {
+	t.Helper()
+	src := &input.Scripted{}
+	g := game.NewHeadlessGame(screenWidth, screenHeight, seed, src)
+	step(g, src, 1)
+	orderMove(t, g, src, 200, 100)
+	step(g, src, ticks/2)
+	orderMove(t, g, src, -150, -120)
+	step(g, src, ticks/2)
+
+	f, err := savegame.Capture(g.World())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestSameSeedPlaysOutTheSame(t *testing.T) {
+	const ticks = 2000
+	a := playScripted(t, 42, ticks)
+	b := playScripted(t, 42, ticks)
+	if !bytes.Equal(a, b) {
+		t.Fatalf("two matches with the same seed and input ended in different states (%d and %d bytes)", len(a), len(b))
+	}
+	if c := playScripted(t, 43, ticks); bytes.Equal(a, c) {
+		t.Fatal("matches with different seeds ended in the same state")
+	}
+}
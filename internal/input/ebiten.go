@@ -0,0 +1,37 @@
+package input
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// Ebiten is the Source of a windowed game: it reads the real mouse and keyboard.
+type Ebiten struct{}
+
+func (Ebiten) CursorPosition() (int, int) {
+	return ebiten.CursorPosition()
+}
+
+func (Ebiten) IsMouseButtonPressed(button ebiten.MouseButton) bool {
+	return ebiten.IsMouseButtonPressed(button)
+}
+
+func (Ebiten) IsMouseButtonJustPressed(button ebiten.MouseButton) bool {
+	return inpututil.IsMouseButtonJustPressed(button)
+}
+
+func (Ebiten) IsMouseButtonJustReleased(button ebiten.MouseButton) bool {
+	return inpututil.IsMouseButtonJustReleased(button)
+}
+
+func (Ebiten) IsKeyPressed(key ebiten.Key) bool {
+	return ebiten.IsKeyPressed(key)
+}
+
+func (Ebiten) IsKeyJustPressed(key ebiten.Key) bool {
+	return inpututil.IsKeyJustPressed(key)
+}
+
+func (Ebiten) Wheel() (float64, float64) {
+	return ebiten.Wheel()
+}
@@ -0,0 +1,43 @@
+// Package input decouples the systems from where player input comes from. Systems read the mouse and
+// keyboard through the Source stored in the world, which is backed by Ebiten in a windowed game and by
+// a scripted source in headless simulations and tests.
+package input
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/yohamta/donburi"
+	"github.com/yohamta/donburi/filter"
+)
+
+// Source provides the state of the mouse and keyboard for the current tick.
+type Source interface {
+	// CursorPosition returns the cursor position in screen coordinates.
+	CursorPosition() (int, int)
+	// IsMouseButtonPressed reports whether a mouse button is held down.
+	IsMouseButtonPressed(button ebiten.MouseButton) bool
+	// IsMouseButtonJustPressed reports whether a mouse button was pressed in this tick.
+	IsMouseButtonJustPressed(button ebiten.MouseButton) bool
+	// IsMouseButtonJustReleased reports whether a mouse button was released in this tick.
+	IsMouseButtonJustReleased(button ebiten.MouseButton) bool
+	// IsKeyPressed reports whether a key is held down.
+	IsKeyPressed(key ebiten.Key) bool
+	// IsKeyJustPressed reports whether a key was pressed in this tick.
+	IsKeyJustPressed(key ebiten.Key) bool
+	// Wheel returns how far the mouse wheel was scrolled in this tick.
+	Wheel() (float64, float64)
+}
+
+// Input is a resource that holds the world's input source.
+type Input struct {
+	Source Source
+}
+
+var InputRes = donburi.NewComponentType[Input]()
+
+var InputQuery = donburi.NewQuery(filter.Contains(InputRes))
+
+// GetInput gets the input source from the world.
+func GetInput(w donburi.World) Source {
+	entry, _ := InputQuery.First(w)
+	return InputRes.Get(entry).Source
+}
@@ -0,0 +1,104 @@
+package input
+
+import "github.com/hajimehoshi/ebiten/v2"
+
+// Scripted is a Source whose state is set by code, for headless simulations and tests. The zero value
+// is ready to use and acts as a null source until something is pressed: the cursor rests in the
+// top-left corner and no button or key is down.
+//
+// Presses and releases become visible to the systems immediately; call EndTick after every simulation
+// step so that "just pressed" and "just released" only last for one tick.
+type Scripted struct {
+	X, Y     int
+	WheelX   float64
+	WheelY   float64
+	buttons  map[ebiten.MouseButton]bool
+	prevBtns map[ebiten.MouseButton]bool
+	keys     map[ebiten.Key]bool
+	prevKeys map[ebiten.Key]bool
+}
+
+// init allocates the button and key state on first use.
+func (s *Scripted) init() {
+	if s.buttons != nil {
+		return
+	}
+	s.buttons = map[ebiten.MouseButton]bool{}
+	s.prevBtns = map[ebiten.MouseButton]bool{}
+	s.keys = map[ebiten.Key]bool{}
+	s.prevKeys = map[ebiten.Key]bool{}
+}
+
+// MoveCursor moves the cursor to a screen position.
+func (s *Scripted) MoveCursor(x, y int) {
+	s.X, s.Y = x, y
+}
+
+// PressButton holds a mouse button down.
+func (s *Scripted) PressButton(button ebiten.MouseButton) {
+	s.init()
+	s.buttons[button] = true
+}
+
+// ReleaseButton lets go of a mouse button.
+func (s *Scripted) ReleaseButton(button ebiten.MouseButton) {
+	s.init()
+	s.buttons[button] = false
+}
+
+// PressKey holds a key down.
+func (s *Scripted) PressKey(key ebiten.Key) {
+	s.init()
+	s.keys[key] = true
+}
+
+// ReleaseKey lets go of a key.
+func (s *Scripted) ReleaseKey(key ebiten.Key) {
+	s.init()
+	s.keys[key] = false
+}
+
+// Scroll scrolls the mouse wheel for the current tick.
+func (s *Scripted) Scroll(x, y float64) {
+	s.WheelX, s.WheelY = x, y
+}
+
+// EndTick marks the end of a simulation step: the current state becomes the previous one and the wheel stops.
+func (s *Scripted) EndTick() {
+	s.init()
+	for b, pressed := range s.buttons {
+		s.prevBtns[b] = pressed
+	}
+	for k, pressed := range s.keys {
+		s.prevKeys[k] = pressed
+	}
+	s.WheelX, s.WheelY = 0, 0
+}
+
+func (s *Scripted) CursorPosition() (int, int) {
+	return s.X, s.Y
+}
+
+func (s *Scripted) IsMouseButtonPressed(button ebiten.MouseButton) bool {
+	return s.buttons[button]
+}
+
+func (s *Scripted) IsMouseButtonJustPressed(button ebiten.MouseButton) bool {
+	return s.buttons[button] && !s.prevBtns[button]
+}
+
+func (s *Scripted) IsMouseButtonJustReleased(button ebiten.MouseButton) bool {
+	return !s.buttons[button] && s.prevBtns[button]
+}
+
+func (s *Scripted) IsKeyPressed(key ebiten.Key) bool {
+	return s.keys[key]
+}
+
+func (s *Scripted) IsKeyJustPressed(key ebiten.Key) bool {
+	return s.keys[key] && !s.prevKeys[key]
+}
+
+func (s *Scripted) Wheel() (float64, float64) {
+	return s.WheelX, s.WheelY
+}
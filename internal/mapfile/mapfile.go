@@ -0,0 +1,223 @@
+// Package mapfile reads and writes designed maps. A map describes the terrain, the spice fields, where each
+// faction starts and the units and buildings already on the map when a match begins.
+//
+// Maps are JSON files. The terrain is stored as one string per row of tiles with one character per tile, so a
+// map can be read and touched up in a text editor:
+//
+//	. sand   r rock   ~ dunes   ^ mountain   s spice sand
+package mapfile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gfeyer/ebit/internal/components"
+	"github.com/gfeyer/ebit/internal/defs"
+	"github.com/gfeyer/ebit/internal/terrain"
+)
+
+// Version is the map file format version. Files written by another version are rejected.
+const Version = 1
+
+// TileSize is the size of a map tile in pixels.
+const TileSize = 32
+
+// tileChars maps each terrain type to the character it is stored as.
+var tileChars = map[terrain.TerrainType]byte{
+	terrain.Sand:      '.',
+	terrain.Rock:      'r',
+	terrain.Dunes:     '~',
+	terrain.Mountain:  '^',
+	terrain.SpiceSand: 's',
+}
+
+// Map is a designed map. Positions are in pixels.
+type Map struct {
+	Version int
+	// Width and Height are the size of the map in tiles.
+	Width  int
+	Height int
+	// Terrain holds one row of tiles per string.
+	Terrain   []string
+	Spice     []Spice
+	Starts    []Start
+	Units     []Entity
+	Buildings []Entity
+}
+
+// Spice is a square spice field. Size is the length of its sides.
+type Spice struct {
+	X, Y   float64
+	Size   float64
+	Amount int
+}
+
+// Start is where a faction starts and the money it starts with. The camera of the faction's player begins there.
+type Start struct {
+	Faction components.Faction
+	X, Y    float64
+	Money   int
+}
+
+// Entity is a unit or building on the map when the match begins. Type is its ID in the definitions.
+type Entity struct {
+	Type    string
+	Faction components.Faction
+	X, Y    float64
+}
+
+// New creates a map of the given size in tiles, covered in sand, with both factions starting in its center.
+func New(width, height int) *Map {
+	m := &Map{Version: Version, Width: width, Height: height}
+	row := strings.Repeat(string(tileChars[terrain.Sand]), width)
+	for y := 0; y < height; y++ {
+		m.Terrain = append(m.Terrain, row)
+	}
+	cx, cy := float64(width*TileSize)/2, float64(height*TileSize)/2
+	for _, faction := range []components.Faction{components.FactionAtreides, components.FactionHarkonnen} {
+		m.Starts = append(m.Starts, Start{Faction: faction, X: cx, Y: cy, Money: 1000})
+	}
+	return m
+}
+
+// Load reads a map file and checks that it is valid.
+func Load(path string) (*Map, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading map: %w", err)
+	}
+	var m Map
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("decoding map: %w", err)
+	}
+	if m.Version != Version {
+		return nil, fmt.Errorf("unsupported map version %d, want %d", m.Version, Version)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid map %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// Save writes a map to a file.
+func (m *Map) Save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding map: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("writing map: %w", err)
+	}
+	return nil
+}
+
+// Validate checks that the terrain covers the map with known tiles, that every faction has a start,
+// that every unit and building is of a known type and that everything placed on the map lies on it.
+func (m *Map) Validate() error {
+	if m.Width <= 0 || m.Height <= 0 {
+		return errors.New("the map has no size")
+	}
+	if len(m.Terrain) != m.Height {
+		return fmt.Errorf("the terrain has %d rows, want %d", len(m.Terrain), m.Height)
+	}
+	for y, row := range m.Terrain {
+		if len(row) != m.Width {
+			return fmt.Errorf("terrain row %d has %d tiles, want %d", y, len(row), m.Width)
+		}
+		for x := 0; x < len(row); x++ {
+			if _, ok := tileType(row[x]); !ok {
+				return fmt.Errorf("unknown terrain %q at tile %d,%d", row[x], x, y)
+			}
+		}
+	}
+	for _, faction := range []components.Faction{components.FactionAtreides, components.FactionHarkonnen} {
+		if m.Start(faction) == nil {
+			return fmt.Errorf("faction %d has no start", faction)
+		}
+	}
+	for _, st := range m.Starts {
+		if !m.Contains(st.X, st.Y) {
+			return fmt.Errorf("the start of faction %d at %g,%g is off the map", st.Faction, st.X, st.Y)
+		}
+	}
+	for _, sp := range m.Spice {
+		if sp.Size <= 0 {
+			return fmt.Errorf("spice field at %g,%g has size %g", sp.X, sp.Y, sp.Size)
+		}
+		if sp.Amount < 0 {
+			return fmt.Errorf("spice field at %g,%g holds %d spice", sp.X, sp.Y, sp.Amount)
+		}
+		if !m.Contains(sp.X, sp.Y) {
+			return fmt.Errorf("spice field at %g,%g is off the map", sp.X, sp.Y)
+		}
+	}
+	for _, u := range m.Units {
+		if _, ok := defs.UnitType(u.Type); !ok {
+			return fmt.Errorf("unknown unit %q", u.Type)
+		}
+		if !m.Contains(u.X, u.Y) {
+			return fmt.Errorf("unit %q at %g,%g is off the map", u.Type, u.X, u.Y)
+		}
+	}
+	for _, b := range m.Buildings {
+		if _, ok := defs.BuildingType(b.Type); !ok {
+			return fmt.Errorf("unknown building %q", b.Type)
+		}
+		if !m.Contains(b.X, b.Y) {
+			return fmt.Errorf("building %q at %g,%g is off the map", b.Type, b.X, b.Y)
+		}
+	}
+	return nil
+}
+
+// Contains reports whether a position in pixels lies on the map.
+func (m *Map) Contains(x, y float64) bool {
+	return x >= 0 && y >= 0 && x < float64(m.Width*TileSize) && y < float64(m.Height*TileSize)
+}
+
+// Start returns the start of a faction, or nil if the map has none for it.
+func (m *Map) Start(faction components.Faction) *Start {
+	for i := range m.Starts {
+		if m.Starts[i].Faction == faction {
+			return &m.Starts[i]
+		}
+	}
+	return nil
+}
+
+// Grid decodes the terrain into a grid of tiles, indexed by row and then column.
+func (m *Map) Grid() [][]terrain.TerrainType {
+	grid := make([][]terrain.TerrainType, len(m.Terrain))
+	for y, row := range m.Terrain {
+		grid[y] = make([]terrain.TerrainType, len(row))
+		for x := 0; x < len(row); x++ {
+			grid[y][x], _ = tileType(row[x])
+		}
+	}
+	return grid
+}
+
+// SetGrid encodes a grid of tiles as the map's terrain.
+func (m *Map) SetGrid(grid [][]terrain.TerrainType) {
+	m.Terrain = m.Terrain[:0]
+	for _, tiles := range grid {
+		row := make([]byte, len(tiles))
+		for x, tt := range tiles {
+			row[x] = tileChars[tt]
+		}
+		m.Terrain = append(m.Terrain, string(row))
+	}
+}
+
+// tileType returns the terrain type a character stands for.
+func tileType(c byte) (terrain.TerrainType, bool) {
+	for tt, tc := range tileChars {
+		if tc == c {
+			return tt, true
+		}
+	}
+	return 0, false
+}
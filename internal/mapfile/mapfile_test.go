@@ -0,0 +1,58 @@
+package mapfile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gfeyer/ebit/internal/components"
+)
+
+// validMap returns a small map with something of everything on it.
+func validMap() *Map {
+	m := New(10, 8)
+	m.Spice = []Spice{{X: 64, Y: 64, Size: 48, Amount: 500}}
+	m.Units = []Entity{{Type: "trike", Faction: components.FactionAtreides, X: 100, Y: 100}}
+	m.Buildings = []Entity{{Type: "refinery", Faction: components.FactionHarkonnen, X: 192, Y: 128}}
+	return m
+}
+
+func TestValidate(t *testing.T) {
+	// The map is 320x256 pixels.
+	for _, tc := range []struct {
+		name   string
+		change func(m *Map)
+		// err is part of the error Validate must return, or empty if the map is valid.
+		err string
+	}{
+		{"valid", func(m *Map) {}, ""},
+		{"spice at the far edge", func(m *Map) { m.Spice[0].X, m.Spice[0].Y = 319, 255 }, ""},
+		{"empty spice field", func(m *Map) { m.Spice[0].Amount = 0 }, ""},
+		{"no size", func(m *Map) { m.Width = 0 }, "no size"},
+		{"short terrain row", func(m *Map) { m.Terrain[2] = m.Terrain[2][1:] }, "terrain row 2"},
+		{"unknown terrain", func(m *Map) { m.Terrain[0] = "x" + m.Terrain[0][1:] }, "unknown terrain"},
+		{"missing start", func(m *Map) { m.Starts = m.Starts[:1] }, "no start"},
+		{"start off the map", func(m *Map) { m.Starts[1].X = 320 }, "start of faction 1"},
+		{"spice of zero size", func(m *Map) { m.Spice[0].Size = 0 }, "size 0"},
+		{"spice of negative size", func(m *Map) { m.Spice[0].Size = -32 }, "size -32"},
+		{"negative spice", func(m *Map) { m.Spice[0].Amount = -1 }, "holds -1 spice"},
+		{"spice off the map", func(m *Map) { m.Spice[0].X = -1 }, "spice field at -1,64 is off the map"},
+		{"unknown unit", func(m *Map) { m.Units[0].Type = "sandworm" }, `unknown unit "sandworm"`},
+		{"unit off the map", func(m *Map) { m.Units[0].Y = 256 }, `unit "trike" at 100,256 is off the map`},
+		{"unknown building", func(m *Map) { m.Buildings[0].Type = "palace" }, `unknown building "palace"`},
+		{"building off the map", func(m *Map) { m.Buildings[0].X = 1000 }, `building "refinery" at 1000,128 is off the map`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := validMap()
+			tc.change(m)
+			err := m.Validate()
+			switch {
+			case tc.err == "" && err != nil:
+				t.Errorf("Validate rejected the map: %v", err)
+			case tc.err != "" && err == nil:
+				t.Errorf("Validate accepted the map, want an error containing %q", tc.err)
+			case tc.err != "" && !strings.Contains(err.Error(), tc.err):
+				t.Errorf("Validate error %q doesn't contain %q", err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+// Package client connects a game to a netplay relay. It sends the local player's commands for each turn
+// and collects the complete turns the relay broadcasts.
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/gfeyer/ebit/internal/command"
+	"github.com/gfeyer/ebit/internal/components"
+	"github.com/gfeyer/ebit/internal/netplay"
+)
+
+// transport is a WebSocket connection: natively a hand-rolled one, in the browser the browser's.
+type transport interface {
+	// Send writes a text message.
+	Send(data []byte) error
+	// Receive blocks until the next message arrives.
+	Receive() ([]byte, error)
+	Close() error
+}
+
+// Client is a connection to a netplay relay.
+type Client struct {
+	t transport
+	// Welcome describes the match the client was placed in.
+	Welcome netplay.Welcome
+
+	mu    sync.Mutex
+	turns map[int][]command.Command
+	err   error
+}
+
+// Connect connects to a relay and waits until the relay has started a match. defs is the hash of the local
+// unit and building definitions; the relay only matches players whose definitions are the same.
+func Connect(rawURL, defs string) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing relay URL: %w", err)
+	}
+	query := u.Query()
+	query.Set("defs", defs)
+	u.RawQuery = query.Encode()
+
+	t, err := dial(u.String())
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := t.Receive()
+	if err != nil {
+		t.Close()
+		return nil, fmt.Errorf("waiting for the match to start: %w", err)
+	}
+	var msg netplay.Message
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Type != netplay.TypeWelcome || msg.Welcome == nil {
+		t.Close()
+		return nil, errors.New("the server didn't start a match")
+	}
+	if msg.Welcome.Defs != defs {
+		t.Close()
+		return nil, fmt.Errorf("the match uses definitions %q, not the local %q", msg.Welcome.Defs, defs)
+	}
+
+	c := &Client{t: t, Welcome: *msg.Welcome, turns: map[int][]command.Command{}}
+	go c.receive()
+	return c, nil
+}
+
+// Faction returns the faction commanded by this client.
+func (c *Client) Faction() components.Faction {
+	return components.Faction(c.Welcome.Faction)
+}
+
+// SendTurn sends the commands the local player gave for a turn.
+func (c *Client) SendTurn(turn int, commands []command.Command) error {
+	if commands == nil {
+		commands = []command.Command{}
+	}
+	encoded, err := json.Marshal(commands)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(netplay.Message{
+		Type: netplay.TypeTurn,
+		Turn: &netplay.Turn{Turn: turn, Orders: []netplay.Orders{{Faction: c.Welcome.Faction, Commands: encoded}}},
+	})
+	if err != nil {
+		return err
+	}
+	return c.t.Send(data)
+}
+
+// Turn returns the commands of every player for a turn, once the relay has broadcast it.
+// A returned turn is forgotten, so every turn can be taken only once.
+func (c *Client) Turn(turn int) ([]command.Command, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	commands, ok := c.turns[turn]
+	if ok {
+		delete(c.turns, turn)
+	}
+	return commands, ok
+}
+
+// Err returns the error that ended the connection, if any.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.t.Close()
+}
+
+// receive collects the turns broadcast by the relay until the connection fails.
+func (c *Client) receive() {
+	for {
+		data, err := c.t.Receive()
+		if err == nil {
+			err = c.handle(data)
+		}
+		if err != nil {
+			c.mu.Lock()
+			c.err = err
+			c.mu.Unlock()
+			return
+		}
+	}
+}
+
+// handle decodes a turn. Every command is attributed to the player who sent it, whatever it claims.
+func (c *Client) handle(data []byte) error {
+	var msg netplay.Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return fmt.Errorf("decoding message: %w", err)
+	}
+	if msg.Type != netplay.TypeTurn || msg.Turn == nil {
+		return nil
+	}
+
+	var commands []command.Command
+	for _, orders := range msg.Turn.Orders {
+		var cmds []command.Command
+		if err := json.Unmarshal(orders.Commands, &cmds); err != nil {
+			return fmt.Errorf("decoding turn %d: %w", msg.Turn.Turn, err)
+		}
+		for _, cmd := range cmds {
+			cmd.Faction = components.Faction(orders.Faction)
+			commands = append(commands, cmd)
+		}
+	}
+
+	c.mu.Lock()
+	c.turns[msg.Turn.Turn] = commands
+	c.mu.Unlock()
+	return nil
+}
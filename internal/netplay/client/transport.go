@@ -0,0 +1,30 @@
+//go:build !js
+
+package client
+
+import "github.com/gfeyer/ebit/internal/netplay/websocket"
+
+// wsTransport is a native WebSocket connection.
+type wsTransport struct {
+	conn *websocket.Conn
+}
+
+func dial(url string) (transport, error) {
+	conn, err := websocket.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	return &wsTransport{conn: conn}, nil
+}
+
+func (t *wsTransport) Send(data []byte) error {
+	return t.conn.WriteMessage(data)
+}
+
+func (t *wsTransport) Receive() ([]byte, error) {
+	return t.conn.ReadMessage()
+}
+
+func (t *wsTransport) Close() error {
+	return t.conn.Close()
+}
@@ -0,0 +1,117 @@
+//go:build js
+
+package client
+
+import (
+	"errors"
+	"sync"
+	"syscall/js"
+)
+
+// jsTransport is a connection through the browser's WebSocket.
+type jsTransport struct {
+	ws js.Value
+	// queue holds the messages that arrived and haven't been received yet. It grows as needed, so the
+	// message handler never waits for the game to catch up.
+	mu    sync.Mutex
+	queue [][]byte
+	// ready is signalled when a message is added to the queue.
+	ready  chan struct{}
+	closed chan struct{}
+	funcs  []js.Func
+}
+
+func dial(url string) (transport, error) {
+	t := &jsTransport{
+		ws:     js.Global().Get("WebSocket").New(url),
+		ready:  make(chan struct{}, 1),
+		closed: make(chan struct{}),
+	}
+	opened := make(chan struct{})
+
+	t.on("open", func(event js.Value) {
+		close(opened)
+	})
+	t.on("message", func(event js.Value) {
+		t.mu.Lock()
+		t.queue = append(t.queue, []byte(event.Get("data").String()))
+		t.mu.Unlock()
+		select {
+		case t.ready <- struct{}{}:
+		default:
+		}
+	})
+	t.on("close", func(event js.Value) {
+		close(t.closed)
+	})
+
+	select {
+	case <-opened:
+		return t, nil
+	case <-t.closed:
+		t.release()
+		return nil, errors.New("couldn't connect to " + url)
+	}
+}
+
+// on registers a handler for a WebSocket event. Handlers run on the browser's event loop and must not block.
+func (t *jsTransport) on(event string, handler func(event js.Value)) {
+	f := js.FuncOf(func(this js.Value, args []js.Value) any {
+		handler(args[0])
+		return nil
+	})
+	t.funcs = append(t.funcs, f)
+	t.ws.Set("on"+event, f)
+}
+
+func (t *jsTransport) Send(data []byte) error {
+	select {
+	case <-t.closed:
+		return errors.New("connection closed")
+	default:
+	}
+	t.ws.Call("send", string(data))
+	return nil
+}
+
+func (t *jsTransport) Receive() ([]byte, error) {
+	for {
+		if data, ok := t.next(); ok {
+			return data, nil
+		}
+		select {
+		case <-t.ready:
+		case <-t.closed:
+			// Deliver what arrived before the connection closed.
+			if data, ok := t.next(); ok {
+				return data, nil
+			}
+			return nil, errors.New("connection closed")
+		}
+	}
+}
+
+// next takes the oldest message off the queue, if there is one.
+func (t *jsTransport) next() ([]byte, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.queue) == 0 {
+		return nil, false
+	}
+	data := t.queue[0]
+	t.queue[0] = nil
+	t.queue = t.queue[1:]
+	return data, true
+}
+
+func (t *jsTransport) Close() error {
+	t.ws.Call("close")
+	return nil
+}
+
+// release frees the event handlers.
+func (t *jsTransport) release() {
+	for _, f := range t.funcs {
+		f.Release()
+	}
+}
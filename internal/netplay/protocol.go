@@ -0,0 +1,57 @@
+// Package netplay implements lockstep multiplayer. Every client runs the whole simulation; the server only
+// relays the commands the players give. The simulation advances in turns of a few ticks, and the commands a
+// player issues during a turn are scheduled a couple of turns ahead, so they reach every client in time.
+// A client only starts a turn once it has received the commands of every player for it.
+//
+// This package holds the protocol and the server side. It does not depend on the game so the server can
+// be built without Ebiten; the game side lives in netplay/client.
+package netplay
+
+import "encoding/json"
+
+const (
+	// TurnTicks is the number of simulation ticks in a turn.
+	TurnTicks = 6
+	// TurnDelay is the number of turns between the turn in which a command is issued and the one it is applied in.
+	// The first TurnDelay turns of a match have no commands.
+	TurnDelay = 2
+)
+
+// Message types.
+const (
+	TypeWelcome = "welcome"
+	TypeTurn    = "turn"
+)
+
+// Message is the envelope of everything sent over the connection, encoded as JSON.
+type Message struct {
+	Type    string
+	Welcome *Welcome `json:",omitempty"`
+	Turn    *Turn    `json:",omitempty"`
+}
+
+// Welcome is sent by the server to every client when a match starts.
+type Welcome struct {
+	// Faction is the faction the client commands.
+	Faction int
+	// Players is the number of players in the match; they command factions 0 to Players-1.
+	Players int
+	// Seed seeds the simulation of every client.
+	Seed uint64
+	// Defs is the hash of the unit and building definitions every player of the match uses.
+	Defs string
+}
+
+// Turn holds the commands of one turn. Clients send their own commands for a turn; the server sends back
+// the commands of every player once all of them have sent theirs.
+type Turn struct {
+	Turn   int
+	Orders []Orders
+}
+
+// Orders are the commands one player gave for a turn, encoded by the client. The server doesn't decode them;
+// it fills in the faction of the player that sent them, which clients use instead of what the commands claim.
+type Orders struct {
+	Faction  int
+	Commands json.RawMessage
+}
@@ -0,0 +1,282 @@
+package netplay
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gfeyer/ebit/internal/netplay/websocket"
+)
+
+// maxTurnsAhead is how far ahead of the last broadcast turn a player may send turns.
+const maxTurnsAhead = 64
+
+// Relay is an http.Handler that gathers connecting clients into matches and relays their turns.
+// A match starts as soon as enough players have connected and ends when one of them disconnects.
+// Clients pass the hash of their unit and building definitions in the defs query parameter; only clients
+// with the same definitions play together, as their simulations would drift apart otherwise.
+type Relay struct {
+	// Players is the number of players in a match.
+	Players int
+
+	mu sync.Mutex
+	// lobbies holds the clients waiting for a match, by the hash of their definitions.
+	lobbies map[string][]*peer
+}
+
+// NewRelay creates a relay for matches of the given number of players.
+func NewRelay(players int) *Relay {
+	return &Relay{Players: players, lobbies: map[string][]*peer{}}
+}
+
+// ServeHTTP upgrades the request to a WebSocket connection and adds the client to the lobby for its definitions.
+// Clients that disconnect while waiting for a match leave the lobby again.
+func (r *Relay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	defs := req.URL.Query().Get("defs")
+	conn, err := websocket.Upgrade(w, req)
+	if err != nil {
+		log.Printf("relay: %v", err)
+		return
+	}
+	log.Printf("relay: %s joined the lobby for definitions %q", req.RemoteAddr, defs)
+	p := newPeer(conn)
+
+	r.mu.Lock()
+	r.lobbies[defs] = append(r.lobbies[defs], p)
+	var players []*peer
+	if len(r.lobbies[defs]) == r.Players {
+		players = r.lobbies[defs]
+		delete(r.lobbies, defs)
+	}
+	r.mu.Unlock()
+
+	if players != nil {
+		go newMatch(players, defs).run()
+		return
+	}
+	go func() {
+		<-p.gone
+		if r.leave(defs, p) {
+			log.Printf("relay: %s left the lobby: %v", req.RemoteAddr, p.err)
+			p.close()
+		}
+	}()
+}
+
+// leave removes a client from the lobby for its definitions, reporting whether it was still waiting there.
+func (r *Relay) leave(defs string, p *peer) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	lobby := r.lobbies[defs]
+	for i, waiting := range lobby {
+		if waiting == p {
+			if len(lobby) == 1 {
+				delete(r.lobbies, defs)
+			} else {
+				r.lobbies[defs] = append(lobby[:i], lobby[i+1:]...)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// peer is a connected client. One goroutine reads its connection for as long as it is open, so the lobby can
+// notice when the client goes away and the match can later take over the messages it sends.
+type peer struct {
+	conn *websocket.Conn
+	// messages carries the messages the client sends.
+	messages chan []byte
+	// gone is closed when reading fails; err then holds why.
+	gone chan struct{}
+	err  error
+	// closed is closed by close, so the reader never waits on a match that has ended.
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newPeer(conn *websocket.Conn) *peer {
+	p := &peer{
+		conn:     conn,
+		messages: make(chan []byte),
+		gone:     make(chan struct{}),
+		closed:   make(chan struct{}),
+	}
+	go p.read()
+	return p
+}
+
+// read forwards the messages of the client until the connection fails or is closed.
+func (p *peer) read() {
+	defer close(p.gone)
+	for {
+		data, err := p.conn.ReadMessage()
+		if err != nil {
+			p.err = err
+			return
+		}
+		select {
+		case p.messages <- data:
+		case <-p.closed:
+			p.err = websocket.ErrClosed
+			return
+		}
+	}
+}
+
+// close closes the connection to the client.
+func (p *peer) close() {
+	p.closeOnce.Do(func() {
+		close(p.closed)
+		p.conn.Close()
+	})
+}
+
+// submission is a turn received from one player of a match.
+type submission struct {
+	player int
+	turn   *Turn
+	err    error
+}
+
+// match relays the turns of one group of players.
+type match struct {
+	players []*peer
+	// defs is the hash of the definitions every player uses.
+	defs  string
+	inbox chan submission
+	// done is closed when the match ends.
+	done chan struct{}
+	// pending holds, per turn, the commands each player has sent so far.
+	pending map[int][]json.RawMessage
+	// next is the next turn to broadcast.
+	next int
+}
+
+func newMatch(players []*peer, defs string) *match {
+	return &match{
+		players: players,
+		defs:    defs,
+		inbox:   make(chan submission),
+		done:    make(chan struct{}),
+		pending: map[int][]json.RawMessage{},
+		next:    TurnDelay,
+	}
+}
+
+// run welcomes the players and relays their turns until one of them disconnects.
+func (m *match) run() {
+	defer func() {
+		close(m.done)
+		for _, p := range m.players {
+			p.close()
+		}
+	}()
+
+	seed := uint64(time.Now().UnixNano())
+	log.Printf("relay: starting a match of %d players with seed %d", len(m.players), seed)
+	for i, p := range m.players {
+		welcome := &Welcome{Faction: i, Players: len(m.players), Seed: seed, Defs: m.defs}
+		if err := send(p.conn, Message{Type: TypeWelcome, Welcome: welcome}); err != nil {
+			log.Printf("relay: welcoming player %d: %v", i, err)
+			return
+		}
+		go m.receive(i, p)
+	}
+
+	for sub := range m.inbox {
+		if sub.err != nil {
+			log.Printf("relay: player %d left: %v", sub.player, sub.err)
+			return
+		}
+		if sub.turn.Turn < m.next || sub.turn.Turn > m.next+maxTurnsAhead {
+			log.Printf("relay: player %d sent turn %d out of order", sub.player, sub.turn.Turn)
+			continue
+		}
+		orders := m.pending[sub.turn.Turn]
+		if orders == nil {
+			orders = make([]json.RawMessage, len(m.players))
+		}
+		// The orders a player sent first stand; sending a turn again can't replace them.
+		if orders[sub.player] != nil {
+			log.Printf("relay: player %d sent turn %d again", sub.player, sub.turn.Turn)
+			continue
+		}
+		if len(sub.turn.Orders) > 0 {
+			orders[sub.player] = sub.turn.Orders[0].Commands
+		} else {
+			orders[sub.player] = json.RawMessage("[]")
+		}
+		m.pending[sub.turn.Turn] = orders
+
+		// Broadcast every turn that is now complete, in order.
+		for m.complete(m.next) {
+			turn := &Turn{Turn: m.next}
+			for player, commands := range m.pending[m.next] {
+				turn.Orders = append(turn.Orders, Orders{Faction: player, Commands: commands})
+			}
+			for i, p := range m.players {
+				if err := send(p.conn, Message{Type: TypeTurn, Turn: turn}); err != nil {
+					log.Printf("relay: sending turn %d to player %d: %v", m.next, i, err)
+					return
+				}
+			}
+			delete(m.pending, m.next)
+			m.next++
+		}
+	}
+}
+
+// complete reports whether every player has sent their commands for a turn.
+func (m *match) complete(turn int) bool {
+	orders, ok := m.pending[turn]
+	if !ok {
+		return false
+	}
+	for _, commands := range orders {
+		if commands == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// receive forwards the turns a player sends to the match until the connection fails or the match ends.
+func (m *match) receive(player int, p *peer) {
+	forward := func(sub submission) bool {
+		select {
+		case m.inbox <- sub:
+			return true
+		case <-m.done:
+			return false
+		}
+	}
+	for {
+		var data []byte
+		select {
+		case data = <-p.messages:
+		case <-p.gone:
+			forward(submission{player: player, err: p.err})
+			return
+		}
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			forward(submission{player: player, err: err})
+			return
+		}
+		if msg.Type == TypeTurn && msg.Turn != nil && !forward(submission{player: player, turn: msg.Turn}) {
+			return
+		}
+	}
+}
+
+// send encodes a message and writes it to a connection.
+func send(conn *websocket.Conn, msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(data)
+}
@@ -0,0 +1,203 @@
+package netplay
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gfeyer/ebit/internal/netplay/websocket"
+)
+
+// startRelay serves a relay for matches of the given size, returning its WebSocket URL.
+func startRelay(t *testing.T, players int) string {
+	t.Helper()
+	srv := httptest.NewServer(NewRelay(players))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+// dial connects a client to the relay.
+func dial(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	conn, err := websocket.Dial(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// receive reads the next message from a connection, failing the test if none arrives in time.
+func receive(t *testing.T, conn *websocket.Conn) Message {
+	t.Helper()
+	type result struct {
+		data []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		data, err := conn.ReadMessage()
+		done <- result{data, err}
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+		var msg Message
+		if err := json.Unmarshal(r.data, &msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a message")
+		return Message{}
+	}
+}
+
+// sendTurn sends a player's commands for a turn.
+func sendTurn(t *testing.T, conn *websocket.Conn, faction, turn int, commands string) {
+	t.Helper()
+	orders := []Orders{{Faction: faction, Commands: json.RawMessage(commands)}}
+	if err := send(conn, Message{Type: TypeTurn, Turn: &Turn{Turn: turn, Orders: orders}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRelayBroadcastsTurnsInOrder(t *testing.T) {
+	url := startRelay(t, 2)
+	clients := []*websocket.Conn{dial(t, url), dial(t, url)}
+
+	var seed uint64
+	for i, conn := range clients {
+		msg := receive(t, conn)
+		if msg.Type != TypeWelcome || msg.Welcome == nil {
+			t.Fatalf("client %d got %+v, want a welcome", i, msg)
+		}
+		if msg.Welcome.Faction != i || msg.Welcome.Players != 2 {
+			t.Errorf("client %d was welcomed as %+v", i, *msg.Welcome)
+		}
+		if i > 0 && msg.Welcome.Seed != seed {
+			t.Errorf("client %d got seed %d, client 0 got %d", i, msg.Welcome.Seed, seed)
+		}
+		seed = msg.Welcome.Seed
+	}
+
+	// Player 0 sends three turns ahead; player 1 sends them out of order. No turn is complete until
+	// both players have sent it, and the turns come out in order.
+	for turn := TurnDelay; turn < TurnDelay+3; turn++ {
+		sendTurn(t, clients[0], 0, turn, `["a"]`)
+	}
+	sendTurn(t, clients[1], 1, TurnDelay+1, `["b1"]`)
+	sendTurn(t, clients[1], 1, TurnDelay, `["b0"]`)
+	sendTurn(t, clients[1], 1, TurnDelay+2, `[]`)
+
+	want := []string{`["b0"]`, `["b1"]`, `[]`}
+	for i, conn := range clients {
+		for n, commands := range want {
+			msg := receive(t, conn)
+			if msg.Type != TypeTurn || msg.Turn == nil {
+				t.Fatalf("client %d got %+v, want a turn", i, msg)
+			}
+			if msg.Turn.Turn != TurnDelay+n {
+				t.Fatalf("client %d got turn %d, want %d", i, msg.Turn.Turn, TurnDelay+n)
+			}
+			if len(msg.Turn.Orders) != 2 {
+				t.Fatalf("turn %d has orders of %d players, want 2", msg.Turn.Turn, len(msg.Turn.Orders))
+			}
+			for faction, orders := range msg.Turn.Orders {
+				if orders.Faction != faction {
+					t.Errorf("turn %d: orders %d are from faction %d", msg.Turn.Turn, faction, orders.Faction)
+				}
+			}
+			if got := string(msg.Turn.Orders[0].Commands); got != `["a"]` {
+				t.Errorf("turn %d: player 0 commands %s, want [\"a\"]", msg.Turn.Turn, got)
+			}
+			if got := string(msg.Turn.Orders[1].Commands); got != commands {
+				t.Errorf("turn %d: player 1 commands %s, want %s", msg.Turn.Turn, got, commands)
+			}
+		}
+	}
+}
+
+func TestRelayDropsClientsThatLeaveTheLobby(t *testing.T) {
+	url := startRelay(t, 2)
+	quitter, err := websocket.Dial(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quitter.Close()
+	// Give the relay a moment to notice.
+	time.Sleep(100 * time.Millisecond)
+
+	first, second := dial(t, url), dial(t, url)
+	for i, conn := range []*websocket.Conn{first, second} {
+		msg := receive(t, conn)
+		if msg.Type != TypeWelcome || msg.Welcome == nil || msg.Welcome.Faction != i {
+			t.Fatalf("client %d got %+v, want a welcome as faction %d", i, msg, i)
+		}
+	}
+
+	// Both players are in a live match: a turn from each is broadcast to both.
+	sendTurn(t, first, 0, TurnDelay, `[]`)
+	sendTurn(t, second, 1, TurnDelay, `[]`)
+	for _, conn := range []*websocket.Conn{first, second} {
+		if msg := receive(t, conn); msg.Type != TypeTurn || msg.Turn == nil || msg.Turn.Turn != TurnDelay {
+			t.Fatalf("got %+v, want turn %d", msg, TurnDelay)
+		}
+	}
+}
+
+func TestRelayOnlyMatchesClientsWithTheSameDefinitions(t *testing.T) {
+	url := startRelay(t, 2)
+	modded := dial(t, url+"?defs=modded")
+	first, second := dial(t, url+"?defs=stock"), dial(t, url+"?defs=stock")
+	for i, conn := range []*websocket.Conn{first, second} {
+		msg := receive(t, conn)
+		if msg.Type != TypeWelcome || msg.Welcome == nil || msg.Welcome.Defs != "stock" {
+			t.Fatalf("client %d got %+v, want a welcome to a match with definitions \"stock\"", i, msg)
+		}
+	}
+
+	// The client with other definitions is still waiting, and is matched with the next one that has them.
+	other := dial(t, url+"?defs=modded")
+	for i, conn := range []*websocket.Conn{modded, other} {
+		if msg := receive(t, conn); msg.Welcome == nil || msg.Welcome.Faction != i || msg.Welcome.Defs != "modded" {
+			t.Fatalf("client %d got %+v, want a welcome as faction %d with definitions \"modded\"", i, msg, i)
+		}
+	}
+}
+
+func TestRelayIgnoresTurnsSentAgain(t *testing.T) {
+	url := startRelay(t, 2)
+	clients := []*websocket.Conn{dial(t, url), dial(t, url)}
+	for _, conn := range clients {
+		receive(t, conn)
+	}
+
+	// Player 0 tries to replace the orders it gave for a turn before player 1 has sent theirs.
+	sendTurn(t, clients[0], 0, TurnDelay, `["first"]`)
+	sendTurn(t, clients[0], 0, TurnDelay, `["second"]`)
+	// Give the relay a moment to take both, as the turns of different players are read concurrently.
+	time.Sleep(100 * time.Millisecond)
+	sendTurn(t, clients[1], 1, TurnDelay, `[]`)
+	// A turn sent again after it was broadcast is ignored as well.
+	sendTurn(t, clients[0], 0, TurnDelay, `["third"]`)
+	sendTurn(t, clients[0], 0, TurnDelay+1, `[]`)
+	sendTurn(t, clients[1], 1, TurnDelay+1, `[]`)
+
+	for i, conn := range clients {
+		msg := receive(t, conn)
+		if msg.Turn == nil || msg.Turn.Turn != TurnDelay {
+			t.Fatalf("client %d got %+v, want turn %d", i, msg, TurnDelay)
+		}
+		if got := string(msg.Turn.Orders[0].Commands); got != `["first"]` {
+			t.Errorf("client %d: player 0 commands %s, want [\"first\"]", i, got)
+		}
+		if msg := receive(t, conn); msg.Turn == nil || msg.Turn.Turn != TurnDelay+1 {
+			t.Fatalf("client %d got %+v, want turn %d", i, msg, TurnDelay+1)
+		}
+	}
+}
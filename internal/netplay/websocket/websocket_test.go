@@ -0,0 +1,312 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// pipe returns the two ends of an in-memory connection: a client end and a server end.
+func pipe(t *testing.T) (client, server *Conn) {
+	t.Helper()
+	a, b := net.Pipe()
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+	return &Conn{conn: a, br: bufio.NewReader(a), client: true}, &Conn{conn: b, br: bufio.NewReader(b)}
+}
+
+// rawPipe returns a server end and the raw connection of the client end, for writing and reading frames byte by byte.
+func rawPipe(t *testing.T) (raw net.Conn, server *Conn) {
+	t.Helper()
+	a, b := net.Pipe()
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+	return a, &Conn{conn: b, br: bufio.NewReader(b)}
+}
+
+// frame encodes a frame the way a client sends it, masked with a fixed key.
+func frame(fin bool, opcode byte, payload []byte) []byte {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	f := []byte{first}
+	switch n := len(payload); {
+	case n < 126:
+		f = append(f, 0x80|byte(n))
+	case n <= 0xFFFF:
+		f = append(f, 0x80|126)
+		f = binary.BigEndian.AppendUint16(f, uint16(n))
+	default:
+		f = append(f, 0x80|127)
+		f = binary.BigEndian.AppendUint64(f, uint64(n))
+	}
+	mask := []byte{0x12, 0x34, 0x56, 0x78}
+	f = append(f, mask...)
+	for i, b := range payload {
+		f = append(f, b^mask[i%4])
+	}
+	return f
+}
+
+// readAsync reads the next message from a connection in the background.
+func readAsync(c *Conn) <-chan []byte {
+	done := make(chan []byte, 1)
+	go func() {
+		data, err := c.ReadMessage()
+		if err != nil {
+			data = []byte("error: " + err.Error())
+		}
+		done <- data
+	}()
+	return done
+}
+
+func TestAcceptKey(t *testing.T) {
+	// The example from RFC 6455, section 1.3.
+	if got, want := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Errorf("acceptKey = %q, want %q", got, want)
+	}
+}
+
+func TestHandshakeAndEcho(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(data); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	conn, err := Dial("ws" + strings.TrimPrefix(srv.URL, "http") + "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for _, msg := range []string{"hello", "", strings.Repeat("x", 70000)} {
+		if err := conn.WriteMessage([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		got, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != msg {
+			t.Errorf("echo of a %d byte message came back as %d bytes", len(msg), len(got))
+		}
+	}
+}
+
+func TestUpgradeRejectsPlainRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := Upgrade(w, r); err == nil {
+			t.Error("Upgrade accepted a plain HTTP request")
+		}
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestDialRejectsOtherSchemes(t *testing.T) {
+	if _, err := Dial("http://localhost/ws"); err == nil {
+		t.Error("Dial accepted an http:// URL")
+	}
+}
+
+func TestClientFramesAreMasked(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	client := &Conn{conn: a, br: bufio.NewReader(a), client: true}
+
+	payload := []byte("secret payload")
+	go client.WriteMessage(payload)
+	raw := make([]byte, 2+4+len(payload))
+	if _, err := io.ReadFull(b, raw); err != nil {
+		t.Fatal(err)
+	}
+	if raw[0] != 0x80|opText {
+		t.Errorf("first byte %#x, want a final text frame", raw[0])
+	}
+	if raw[1] != 0x80|byte(len(payload)) {
+		t.Errorf("second byte %#x, want the mask bit and length %d", raw[1], len(payload))
+	}
+	if bytes.Contains(raw, payload) {
+		t.Error("the payload was sent in the clear")
+	}
+	mask, masked := raw[2:6], raw[6:]
+	for i := range masked {
+		masked[i] ^= mask[i%4]
+	}
+	if !bytes.Equal(masked, payload) {
+		t.Errorf("unmasked payload %q, want %q", masked, payload)
+	}
+}
+
+func TestServerFramesAreNotMasked(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	server := &Conn{conn: b, br: bufio.NewReader(b)}
+
+	go server.WriteMessage([]byte("hi"))
+	raw := make([]byte, 4)
+	if _, err := io.ReadFull(a, raw); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x80 | opText, 2, 'h', 'i'}; !bytes.Equal(raw, want) {
+		t.Errorf("frame % x, want % x", raw, want)
+	}
+}
+
+func TestLengthEncodings(t *testing.T) {
+	for _, tc := range []struct {
+		size   int
+		header []byte
+	}{
+		{0, []byte{0x81, 0}},
+		{125, []byte{0x81, 125}},
+		{126, []byte{0x81, 126, 0, 126}},
+		{0xFFFF, []byte{0x81, 126, 0xFF, 0xFF}},
+		{0x10000, []byte{0x81, 127, 0, 0, 0, 0, 0, 1, 0, 0}},
+	} {
+		// The server's frame starts with the expected header.
+		a, b := net.Pipe()
+		server := &Conn{conn: b, br: bufio.NewReader(b)}
+		payload := bytes.Repeat([]byte{'z'}, tc.size)
+		go server.WriteMessage(payload)
+		raw := make([]byte, len(tc.header)+tc.size)
+		if _, err := io.ReadFull(a, raw); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(raw[:len(tc.header)], tc.header) {
+			t.Errorf("%d bytes: header % x, want % x", tc.size, raw[:len(tc.header)], tc.header)
+		}
+		a.Close()
+		b.Close()
+
+		// And a client's masked frame of the same size reads back whole.
+		client, server := pipe(t)
+		done := readAsync(server)
+		if err := client.WriteMessage(payload); err != nil {
+			t.Fatal(err)
+		}
+		if got := <-done; !bytes.Equal(got, payload) {
+			t.Errorf("%d bytes: read back %d bytes", tc.size, len(got))
+		}
+	}
+}
+
+func TestFragmentedMessageWithPing(t *testing.T) {
+	raw, server := rawPipe(t)
+	done := readAsync(server)
+
+	// A message in three fragments, with a ping between the first two.
+	go func() {
+		raw.Write(frame(false, opText, []byte("Hello, ")))
+		raw.Write(frame(true, opPing, []byte("are you there")))
+		raw.Write(frame(false, opContinuation, []byte("frag")))
+		raw.Write(frame(true, opContinuation, []byte("mented world")))
+	}()
+
+	// The ping is answered with a pong carrying the same payload while the message is being read.
+	pong := make([]byte, 2+len("are you there"))
+	if _, err := io.ReadFull(raw, pong); err != nil {
+		t.Fatal(err)
+	}
+	if pong[0] != 0x80|opPong || string(pong[2:]) != "are you there" {
+		t.Errorf("answer to ping % x, want a pong with the ping's payload", pong)
+	}
+	if got := string(<-done); got != "Hello, fragmented world" {
+		t.Errorf("message %q, want %q", got, "Hello, fragmented world")
+	}
+}
+
+func TestPongIsIgnored(t *testing.T) {
+	raw, server := rawPipe(t)
+	done := readAsync(server)
+	go func() {
+		raw.Write(frame(true, opPong, []byte("unsolicited")))
+		raw.Write(frame(true, opBinary, []byte{1, 2, 3}))
+	}()
+	if got := <-done; !bytes.Equal(got, []byte{1, 2, 3}) {
+		t.Errorf("message % x, want 01 02 03", got)
+	}
+}
+
+func TestClose(t *testing.T) {
+	raw, server := rawPipe(t)
+	errs := make(chan error, 1)
+	go func() {
+		_, err := server.ReadMessage()
+		errs <- err
+	}()
+	go raw.Write(frame(true, opClose, nil))
+
+	// The close frame is answered with one of its own and reading reports the connection closed.
+	answer := make([]byte, 2)
+	if _, err := io.ReadFull(raw, answer); err != nil {
+		t.Fatal(err)
+	}
+	if answer[0] != 0x80|opClose {
+		t.Errorf("answer % x, want a close frame", answer)
+	}
+	if err := <-errs; !errors.Is(err, ErrClosed) {
+		t.Errorf("ReadMessage error %v, want ErrClosed", err)
+	}
+}
+
+func TestCloseSendsCloseFrame(t *testing.T) {
+	client, server := pipe(t)
+	errs := make(chan error, 1)
+	go func() {
+		_, err := server.ReadMessage()
+		errs <- err
+	}()
+	go client.Close()
+	if err := <-errs; !errors.Is(err, ErrClosed) {
+		t.Errorf("ReadMessage error %v, want ErrClosed", err)
+	}
+}
+
+func TestOversizedMessagesAreRejected(t *testing.T) {
+	raw, server := rawPipe(t)
+	done := readAsync(server)
+	go func() {
+		half := bytes.Repeat([]byte{'x'}, MaxMessageSize/2+1)
+		raw.Write(frame(false, opText, half))
+		raw.Write(frame(true, opContinuation, half))
+	}()
+	if got := string(<-done); !strings.HasPrefix(got, "error:") {
+		t.Errorf("read a message of %d bytes, want an error", len(got))
+	}
+}
@@ -0,0 +1,205 @@
+// Package pathfinding finds routes for ground units across the terrain grid.
+package pathfinding
+
+import (
+	"container/heap"
+	"math"
+
+	"github.com/gfeyer/ebit/internal/components"
+	"github.com/gfeyer/ebit/internal/terrain"
+)
+
+// Grid describes which tiles a unit can drive over. It wraps the terrain and an optional
+// set of tiles blocked by obstacles such as buildings.
+type Grid struct {
+	Terrain *terrain.Terrain
+	// Blocked marks tiles occupied by obstacles, indexed [y][x]. It may be nil.
+	Blocked [][]bool
+}
+
+// IsBlocked reports whether a tile cannot be driven over.
+func (g *Grid) IsBlocked(tx, ty int) bool {
+	if !g.Terrain.Tile(tx, ty).IsPassable() {
+		return true
+	}
+	return g.Blocked != nil && g.Blocked[ty][tx]
+}
+
+// node is an entry in the A* open set.
+type node struct {
+	x, y  int
+	f     float64
+	index int
+}
+
+// openSet is a min-heap of nodes ordered by their estimated total cost.
+type openSet []*node
+
+func (o openSet) Len() int           { return len(o) }
+func (o openSet) Less(i, j int) bool { return o[i].f < o[j].f }
+func (o openSet) Swap(i, j int) {
+	o[i], o[j] = o[j], o[i]
+	o[i].index = i
+	o[j].index = j
+}
+func (o *openSet) Push(x any) {
+	n := x.(*node)
+	n.index = len(*o)
+	*o = append(*o, n)
+}
+func (o *openSet) Pop() any {
+	old := *o
+	n := old[len(old)-1]
+	*o = old[:len(old)-1]
+	return n
+}
+
+// neighbors lists the eight directions a unit can move from a tile.
+var neighbors = [8][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}, {1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+
+// octile is the A* heuristic for a grid that allows diagonal moves.
+func octile(x0, y0, x1, y1 int) float64 {
+	dx := math.Abs(float64(x1 - x0))
+	dy := math.Abs(float64(y1 - y0))
+	return dx + dy + (math.Sqrt2-2)*math.Min(dx, dy)
+}
+
+// FindPath returns the waypoints, in world coordinates, leading from (sx, sy) to (gx, gy).
+// If the goal cannot be reached, the path leads to the reachable tile closest to it.
+// A unit that starts on a blocked tile may drive through blocked tiles until it gets clear.
+func FindPath(g *Grid, sx, sy, gx, gy float64) []components.Pos {
+	t := g.Terrain
+	startX, startY := t.TileAt(sx, sy)
+	goalX, goalY := t.TileAt(gx, gy)
+	if !t.InBounds(startX, startY) || !t.InBounds(goalX, goalY) {
+		return nil
+	}
+	if startX == goalX && startY == goalY {
+		return []components.Pos{{X: gx, Y: gy}}
+	}
+
+	idx := func(x, y int) int { return y*t.Width + x }
+	gScore := make([]float64, t.Width*t.Height)
+	for i := range gScore {
+		gScore[i] = math.Inf(1)
+	}
+	cameFrom := make([]int, t.Width*t.Height)
+	closed := make([]bool, t.Width*t.Height)
+
+	open := &openSet{}
+	gScore[idx(startX, startY)] = 0
+	cameFrom[idx(startX, startY)] = -1
+	heap.Push(open, &node{x: startX, y: startY, f: octile(startX, startY, goalX, goalY)})
+
+	// Remember the explored tile closest to the goal in case the goal is unreachable.
+	bestX, bestY := startX, startY
+	bestH := octile(startX, startY, goalX, goalY)
+
+	for open.Len() > 0 {
+		cur := heap.Pop(open).(*node)
+		ci := idx(cur.x, cur.y)
+		if closed[ci] {
+			continue
+		}
+		closed[ci] = true
+
+		if h := octile(cur.x, cur.y, goalX, goalY); h < bestH {
+			bestH, bestX, bestY = h, cur.x, cur.y
+		}
+		if cur.x == goalX && cur.y == goalY {
+			break
+		}
+
+		curBlocked := g.IsBlocked(cur.x, cur.y)
+		for _, d := range neighbors {
+			nx, ny := cur.x+d[0], cur.y+d[1]
+			if !t.InBounds(nx, ny) || closed[idx(nx, ny)] {
+				continue
+			}
+			if g.IsBlocked(nx, ny) && !curBlocked {
+				continue
+			}
+			// Don't cut corners around blocked tiles when moving diagonally.
+			if d[0] != 0 && d[1] != 0 && !curBlocked && (g.IsBlocked(cur.x+d[0], cur.y) || g.IsBlocked(cur.x, cur.y+d[1])) {
+				continue
+			}
+
+			cost := 1.0
+			if d[0] != 0 && d[1] != 0 {
+				cost = math.Sqrt2
+			}
+			ni := idx(nx, ny)
+			tentative := gScore[ci] + cost
+			if tentative < gScore[ni] {
+				gScore[ni] = tentative
+				cameFrom[ni] = ci
+				heap.Push(open, &node{x: nx, y: ny, f: tentative + octile(nx, ny, goalX, goalY)})
+			}
+		}
+	}
+
+	// Walk back from the goal (or the closest reachable tile) to the start.
+	var tiles [][2]int
+	for i := idx(bestX, bestY); i != -1; i = cameFrom[i] {
+		tiles = append(tiles, [2]int{i % t.Width, i / t.Width})
+	}
+	for i, j := 0, len(tiles)-1; i < j; i, j = i+1, j-1 {
+		tiles[i], tiles[j] = tiles[j], tiles[i]
+	}
+
+	path := smooth(g, tiles)
+	waypoints := make([]components.Pos, 0, len(path))
+	half := float64(t.TileSize) / 2
+	for _, tile := range path[1:] {
+		waypoints = append(waypoints, components.Pos{X: float64(tile[0]*t.TileSize) + half, Y: float64(tile[1]*t.TileSize) + half})
+	}
+	// End exactly on the goal when it was reached.
+	if bestX == goalX && bestY == goalY {
+		if len(waypoints) > 0 {
+			waypoints[len(waypoints)-1] = components.Pos{X: gx, Y: gy}
+		} else {
+			waypoints = append(waypoints, components.Pos{X: gx, Y: gy})
+		}
+	}
+	// Stay put when no tile closer to the goal can be reached.
+	if len(waypoints) == 0 {
+		waypoints = append(waypoints, components.Pos{X: sx, Y: sy})
+	}
+	return waypoints
+}
+
+// smooth removes intermediate tiles that can be skipped by driving in a straight line.
+func smooth(g *Grid, tiles [][2]int) [][2]int {
+	if len(tiles) <= 2 {
+		return tiles
+	}
+	result := [][2]int{tiles[0]}
+	anchor := 0
+	for i := 2; i < len(tiles); i++ {
+		if !lineOfSight(g, tiles[anchor], tiles[i]) {
+			result = append(result, tiles[i-1])
+			anchor = i - 1
+		}
+	}
+	return append(result, tiles[len(tiles)-1])
+}
+
+// lineOfSight reports whether the straight line between two tile centers only crosses open tiles.
+func lineOfSight(g *Grid, a, b [2]int) bool {
+	dx := float64(b[0] - a[0])
+	dy := float64(b[1] - a[1])
+	steps := int(math.Ceil(math.Max(math.Abs(dx), math.Abs(dy)) * 4))
+	for s := 0; s <= steps; s++ {
+		f := float64(s) / float64(steps)
+		x := float64(a[0]) + 0.5 + dx*f
+		y := float64(a[1]) + 0.5 + dy*f
+		// Check the tile under the sample and a small margin around it so units don't clip corners.
+		for _, o := range [4][2]float64{{-0.3, -0.3}, {0.3, -0.3}, {-0.3, 0.3}, {0.3, 0.3}} {
+			tx, ty := int(math.Floor(x+o[0])), int(math.Floor(y+o[1]))
+			if (tx != a[0] || ty != a[1]) && g.IsBlocked(tx, ty) {
+				return false
+			}
+		}
+	}
+	return true
+}
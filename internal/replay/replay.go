@@ -0,0 +1,115 @@
+// Package replay records the commands of a match so it can be simulated again. Because the simulation
+// is deterministic, the seed and the commands applied in every tick are all that is needed to reproduce
+// a match exactly.
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/gfeyer/ebit/internal/command"
+	"github.com/gfeyer/ebit/internal/mapfile"
+)
+
+// Version is the replay file format version. Files written by another version are rejected.
+const Version = 2
+
+// Frame holds the commands applied in one tick.
+type Frame struct {
+	Tick     int
+	Commands []command.Command
+}
+
+// Replay is a recorded match. Only ticks in which commands were applied have a frame.
+type Replay struct {
+	Version int
+	Seed    uint64
+	// ScreenWidth and ScreenHeight are the screen size of the recorded game, which the map size is derived from.
+	ScreenWidth  int
+	ScreenHeight int
+	// Map is the designed map the match was played on, or nil if the map was generated from the seed.
+	Map *mapfile.Map `json:",omitempty"`
+	// Defs is the hash of the unit and building definitions the match was played with.
+	Defs   string
+	Frames []Frame
+}
+
+// New creates an empty replay for a match on a designed map, or on a generated one if m is nil,
+// played with the definitions of the given hash.
+func New(seed uint64, screenWidth, screenHeight int, m *mapfile.Map, defs string) *Replay {
+	return &Replay{Version: Version, Seed: seed, ScreenWidth: screenWidth, ScreenHeight: screenHeight, Map: m, Defs: defs}
+}
+
+// Record adds the commands applied in a tick. Ticks without commands are not stored.
+func (r *Replay) Record(tick int, commands []command.Command) {
+	if len(commands) == 0 {
+		return
+	}
+	// The command buffer reuses its slices, so keep a copy.
+	r.Frames = append(r.Frames, Frame{Tick: tick, Commands: append([]command.Command(nil), commands...)})
+}
+
+// LastTick returns the last tick in which commands were applied, or -1 if there are none.
+func (r *Replay) LastTick() int {
+	if len(r.Frames) == 0 {
+		return -1
+	}
+	return r.Frames[len(r.Frames)-1].Tick
+}
+
+// Save writes the replay to a file.
+func (r *Replay) Save(path string) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("encoding replay: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("writing replay: %w", err)
+	}
+	return nil
+}
+
+// Load reads a replay from a file.
+func Load(path string) (*Replay, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading replay: %w", err)
+	}
+	var r Replay
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("decoding replay: %w", err)
+	}
+	if r.Version != Version {
+		return nil, fmt.Errorf("unsupported replay version %d, want %d", r.Version, Version)
+	}
+	return &r, nil
+}
+
+// Playback hands out the commands of a replay tick by tick.
+type Playback struct {
+	replay *Replay
+	next   int
+}
+
+// NewPlayback starts playing a replay from its first tick.
+func NewPlayback(r *Replay) *Playback {
+	return &Playback{replay: r}
+}
+
+// Commands returns the commands to apply in a tick. Ticks must be requested in increasing order.
+func (p *Playback) Commands(tick int) []command.Command {
+	frames := p.replay.Frames
+	for p.next < len(frames) && frames[p.next].Tick < tick {
+		p.next++
+	}
+	if p.next < len(frames) && frames[p.next].Tick == tick {
+		return frames[p.next].Commands
+	}
+	return nil
+}
+
+// Done reports whether all commands of the replay have been handed out before a tick.
+func (p *Playback) Done(tick int) bool {
+	return tick > p.replay.LastTick()
+}
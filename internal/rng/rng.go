@@ -0,0 +1,55 @@
+// Package rng provides the seeded random number generator all gameplay code draws from,
+// so that the same seed and the same inputs always produce the same game.
+package rng
+
+import (
+	"math/rand/v2"
+
+	"github.com/yohamta/donburi"
+	"github.com/yohamta/donburi/filter"
+)
+
+// RNG is a resource that holds the game's random number generator.
+// Its state can be saved and restored to continue the exact same sequence.
+type RNG struct {
+	// Seed is the seed the generator was created with.
+	Seed uint64
+	src  *rand.PCG
+	r    *rand.Rand
+}
+
+var RNGRes = donburi.NewComponentType[RNG]()
+
+var RNGQuery = donburi.NewQuery(filter.Contains(RNGRes))
+
+// New creates a random number generator from a seed.
+func New(seed uint64) *RNG {
+	src := rand.NewPCG(seed, seed^0x9e3779b97f4a7c15)
+	return &RNG{Seed: seed, src: src, r: rand.New(src)}
+}
+
+// GetRNG gets the random number generator from the world.
+func GetRNG(w donburi.World) *RNG {
+	entry, _ := RNGQuery.First(w)
+	return RNGRes.Get(entry)
+}
+
+// Intn returns a random int in [0, n). It panics if n <= 0.
+func (g *RNG) Intn(n int) int {
+	return g.r.IntN(n)
+}
+
+// Float64 returns a random float64 in [0.0, 1.0).
+func (g *RNG) Float64() float64 {
+	return g.r.Float64()
+}
+
+// State returns the internal state of the generator.
+func (g *RNG) State() ([]byte, error) {
+	return g.src.MarshalBinary()
+}
+
+// SetState restores a state returned by State.
+func (g *RNG) SetState(state []byte) error {
+	return g.src.UnmarshalBinary(state)
+}
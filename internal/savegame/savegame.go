@@ -0,0 +1,316 @@
+// Package savegame persists the state of a match to a file and restores it.
+package savegame
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/gfeyer/ebit/internal/camera"
+	"github.com/gfeyer/ebit/internal/command"
+	"github.com/gfeyer/ebit/internal/components"
+	"github.com/gfeyer/ebit/internal/defs"
+	"github.com/gfeyer/ebit/internal/factory"
+	"github.com/gfeyer/ebit/internal/fog"
+	"github.com/gfeyer/ebit/internal/rng"
+	"github.com/gfeyer/ebit/internal/settings"
+	"github.com/gfeyer/ebit/internal/terrain"
+	"github.com/yohamta/donburi"
+	"github.com/yohamta/donburi/filter"
+)
+
+// Version is the save file format version. Files written by another version are rejected.
+const Version = 5
+
+// File is the on-disk representation of a match. Entity references hold the entity IDs the
+// referenced entities had when the game was saved; they are remapped when the file is loaded.
+// Sprites are not stored: they are rebuilt from the unit and building types.
+type File struct {
+	Version   int
+	MapWidth  int
+	MapHeight int
+	// Defs is the hash of the unit and building definitions the match was played with.
+	Defs      string
+	Camera    camera.Camera
+	Terrain   terrain.Terrain
+	Fog       fog.Fog
+	Tick      int
+	Seed      uint64
+	RNG       []byte
+	Players   []Player
+	Units     []Unit
+	Buildings []Building
+	Spice     []Spice
+}
+
+// Player is the saved state of one faction.
+type Player struct {
+	Player       components.Player
+	Construction components.Construction
+	AI           *components.AI `json:",omitempty"`
+}
+
+// Unit is the saved state of a unit.
+type Unit struct {
+	ID        donburi.Entity
+	Type      components.UnitType
+	Faction   components.Faction
+	Position  components.Pos
+	Velocity  components.Vel
+	Target    components.Target
+	Health    components.Health
+	Selected  bool
+	Harvester *components.HarvesterData `json:",omitempty"`
+	Weapon    *components.Weapon        `json:",omitempty"`
+	Attack    *components.Attack        `json:",omitempty"`
+}
+
+// Building is the saved state of a building.
+type Building struct {
+	ID         donburi.Entity
+	Type       components.BuildingType
+	Faction    components.Faction
+	Position   components.Pos
+	Health     components.Health
+	Selected   bool
+	Production components.Production
+	Weapon     *components.Weapon `json:",omitempty"`
+	Attack     *components.Attack `json:",omitempty"`
+}
+
+// Spice is the saved state of a spice field.
+type Spice struct {
+	ID       donburi.Entity
+	Position components.Pos
+	Size     float64
+	Amount   int
+}
+
+var (
+	qPlayers   = donburi.NewQuery(filter.Contains(components.PlayerRes))
+	qUnits     = donburi.NewQuery(filter.Contains(components.Position, components.UnitRes, components.OwnerRes))
+	qBuildings = donburi.NewQuery(filter.Contains(components.Position, components.OwnerRes, components.BuildingRes))
+	qSpice     = donburi.NewQuery(filter.Contains(components.Position, components.SpiceRes, components.SpiceAmountRes))
+)
+
+// Save writes the state of the world to a file.
+func Save(w donburi.World, path string) error {
+	f, err := Capture(w)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("encoding save file: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("writing save file: %w", err)
+	}
+	return nil
+}
+
+// Load reads a save file and restores it into a world. The world must already contain the game's
+// resources (settings, camera, terrain, fog, menus) but no players, units, buildings or spice.
+func Load(w donburi.World, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading save file: %w", err)
+	}
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("decoding save file: %w", err)
+	}
+	if f.Version != Version {
+		return fmt.Errorf("unsupported save file version %d, want %d", f.Version, Version)
+	}
+	return Restore(w, &f)
+}
+
+// Capture collects the state of the world into a save file.
+func Capture(w donburi.World) (*File, error) {
+	s := settings.GetSettings(w)
+	cameraEntry, _ := camera.CameraQuery.First(w)
+	r := rng.GetRNG(w)
+	state, err := r.State()
+	if err != nil {
+		return nil, fmt.Errorf("saving random number generator: %w", err)
+	}
+
+	f := &File{
+		Version:   Version,
+		MapWidth:  s.MapWidth,
+		MapHeight: s.MapHeight,
+		Defs:      defs.GetDefs(w).Hash(),
+		Camera:    *camera.CameraRes.Get(cameraEntry),
+		Terrain:   *terrain.GetTerrain(w),
+		Fog:       *fog.GetFog(w),
+		Tick:      command.GetBuffer(w).Tick,
+		Seed:      r.Seed,
+		RNG:       state,
+	}
+
+	qPlayers.Each(w, func(entry *donburi.Entry) {
+		p := Player{Player: *components.PlayerRes.Get(entry)}
+		if entry.HasComponent(components.ConstructionRes) {
+			p.Construction = *components.ConstructionRes.Get(entry)
+		}
+		if entry.HasComponent(components.AIRes) {
+			ai := *components.AIRes.Get(entry)
+			p.AI = &ai
+		}
+		f.Players = append(f.Players, p)
+	})
+
+	qUnits.Each(w, func(entry *donburi.Entry) {
+		u := Unit{
+			ID:       entry.Entity(),
+			Type:     components.UnitRes.Get(entry).Type,
+			Faction:  components.OwnerRes.Get(entry).Faction,
+			Position: *components.Position.Get(entry),
+			Velocity: *components.Velocity.Get(entry),
+			Target:   *components.TargetRes.Get(entry),
+			Health:   *components.HealthRes.Get(entry),
+			Selected: components.SelectableRes.Get(entry).Selected,
+		}
+		if entry.HasComponent(components.HarvesterRes) {
+			harvester := *components.HarvesterRes.Get(entry)
+			u.Harvester = &harvester
+		}
+		if entry.HasComponent(components.WeaponRes) {
+			weapon := *components.WeaponRes.Get(entry)
+			u.Weapon = &weapon
+		}
+		if entry.HasComponent(components.AttackRes) {
+			attack := *components.AttackRes.Get(entry)
+			u.Attack = &attack
+		}
+		f.Units = append(f.Units, u)
+	})
+
+	qBuildings.Each(w, func(entry *donburi.Entry) {
+		btype, _ := factory.BuildingTypeOf(entry)
+		b := Building{
+			ID:       entry.Entity(),
+			Type:     btype,
+			Faction:  components.OwnerRes.Get(entry).Faction,
+			Position: *components.Position.Get(entry),
+			Health:   *components.HealthRes.Get(entry),
+			Selected: components.SelectableRes.Get(entry).Selected,
+		}
+		if entry.HasComponent(components.ProductionRes) {
+			b.Production = *components.ProductionRes.Get(entry)
+		}
+		if entry.HasComponent(components.WeaponRes) {
+			weapon := *components.WeaponRes.Get(entry)
+			b.Weapon = &weapon
+		}
+		if entry.HasComponent(components.AttackRes) {
+			attack := *components.AttackRes.Get(entry)
+			b.Attack = &attack
+		}
+		f.Buildings = append(f.Buildings, b)
+	})
+
+	qSpice.Each(w, func(entry *donburi.Entry) {
+		f.Spice = append(f.Spice, Spice{
+			ID:       entry.Entity(),
+			Position: *components.Position.Get(entry),
+			Size:     components.SizeRes.Get(entry).W,
+			Amount:   components.SpiceAmountRes.Get(entry).Amount,
+		})
+	})
+
+	return f, nil
+}
+
+// Restore recreates the state described by a save file in a world. Entities are created through
+// the factory so they get their sprites back, and entity references are remapped to the new entities.
+// A save made with other unit and building definitions than the world's is refused and leaves the world as it is.
+func Restore(w donburi.World, f *File) error {
+	if hash := defs.GetDefs(w).Hash(); hash != f.Defs {
+		return fmt.Errorf("the save was made with definitions %q, not the local %q", f.Defs, hash)
+	}
+
+	s := settings.GetSettings(w)
+	s.MapWidth, s.MapHeight = f.MapWidth, f.MapHeight
+
+	cameraEntry, _ := camera.CameraQuery.First(w)
+	*camera.CameraRes.Get(cameraEntry) = f.Camera
+	*terrain.GetTerrain(w) = f.Terrain
+	*fog.GetFog(w) = f.Fog
+	command.GetBuffer(w).Tick = f.Tick
+
+	for _, p := range f.Players {
+		var e donburi.Entity
+		if p.AI != nil {
+			e = w.Create(components.PlayerRes, components.ConstructionRes, components.AIRes)
+			*components.AIRes.Get(w.Entry(e)) = *p.AI
+		} else {
+			e = w.Create(components.PlayerRes, components.ConstructionRes)
+		}
+		entry := w.Entry(e)
+		*components.PlayerRes.Get(entry) = p.Player
+		*components.ConstructionRes.Get(entry) = p.Construction
+	}
+
+	// Create every entity first, remembering which new entity replaces which saved one.
+	remap := map[donburi.Entity]donburi.Entity{}
+	for _, sp := range f.Spice {
+		e := factory.CreateSpiceField(w, sp.Position.X, sp.Position.Y, sp.Size, sp.Amount)
+		remap[sp.ID] = e
+	}
+	for _, b := range f.Buildings {
+		e := factory.CreateBuilding(w, b.Faction, b.Type, b.Position.X, b.Position.Y)
+		entry := w.Entry(e)
+		*components.HealthRes.Get(entry) = b.Health
+		components.SelectableRes.Get(entry).Selected = b.Selected
+		if entry.HasComponent(components.ProductionRes) {
+			*components.ProductionRes.Get(entry) = b.Production
+		}
+		if b.Weapon != nil && entry.HasComponent(components.WeaponRes) {
+			*components.WeaponRes.Get(entry) = *b.Weapon
+		}
+		remap[b.ID] = e
+	}
+	for _, u := range f.Units {
+		e := factory.CreateUnit(w, u.Faction, u.Type, u.Position.X, u.Position.Y)
+		entry := w.Entry(e)
+		*components.Velocity.Get(entry) = u.Velocity
+		*components.TargetRes.Get(entry) = u.Target
+		*components.HealthRes.Get(entry) = u.Health
+		components.SelectableRes.Get(entry).Selected = u.Selected
+		if u.Weapon != nil && entry.HasComponent(components.WeaponRes) {
+			*components.WeaponRes.Get(entry) = *u.Weapon
+		}
+		remap[u.ID] = e
+	}
+
+	// Then restore the references between entities. References to entities that no longer
+	// existed when the game was saved become null.
+	for _, u := range f.Units {
+		entry := w.Entry(remap[u.ID])
+		if u.Harvester != nil && entry.HasComponent(components.HarvesterRes) {
+			harvester := *u.Harvester
+			harvester.TargetSpice = remap[harvester.TargetSpice]
+			harvester.TargetRefinery = remap[harvester.TargetRefinery]
+			*components.HarvesterRes.Get(entry) = harvester
+		}
+		if u.Attack != nil && entry.HasComponent(components.AttackRes) {
+			components.AttackRes.Get(entry).Target = remap[u.Attack.Target]
+		}
+	}
+	for _, b := range f.Buildings {
+		entry := w.Entry(remap[b.ID])
+		if b.Attack != nil && entry.HasComponent(components.AttackRes) {
+			components.AttackRes.Get(entry).Target = remap[b.Attack.Target]
+		}
+	}
+
+	// Restore the generator last, so nothing above can disturb it.
+	r := rng.GetRNG(w)
+	r.Seed = f.Seed
+	if err := r.SetState(f.RNG); err != nil {
+		return fmt.Errorf("restoring random number generator: %w", err)
+	}
+	return nil
+}
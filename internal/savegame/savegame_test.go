@@ -0,0 +1,33 @@
+package savegame_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gfeyer/ebit/internal/defs"
+	"github.com/gfeyer/ebit/internal/game"
+	"github.com/gfeyer/ebit/internal/input"
+	"github.com/gfeyer/ebit/internal/savegame"
+)
+
+func TestRestoreRefusesOtherDefinitions(t *testing.T) {
+	g := game.NewHeadlessGame(1280, 720, 1, &input.Scripted{})
+	g.Step()
+	f, err := savegame.Capture(g.World())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := defs.GetDefs(g.World()).Hash(); f.Defs != want {
+		t.Fatalf("the save records definitions %q, want %q", f.Defs, want)
+	}
+
+	f.Defs = "0123456789abcdef"
+	before := g.World().Len()
+	err = savegame.Restore(g.World(), f)
+	if err == nil || !strings.Contains(err.Error(), "definitions") {
+		t.Fatalf("Restore error %v, want one about the definitions", err)
+	}
+	if after := g.World().Len(); after != before {
+		t.Errorf("the refused save changed the world from %d to %d entities", before, after)
+	}
+}
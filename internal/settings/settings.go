@@ -11,6 +11,8 @@ type Settings struct {
 	ScreenHeight int
 	MapWidth     int
 	MapHeight    int
+	// Headless runs the simulation without a window: no sprites are created and nothing is drawn.
+	Headless bool
 }
 
 var SettingsRes = donburi.NewComponentType[Settings]()
@@ -0,0 +1,117 @@
+// Package spatial indexes entities in a uniform grid, so the entities near a position can be found without
+// looking at every entity in the world.
+package spatial
+
+import (
+	"math"
+
+	"github.com/yohamta/donburi"
+	"github.com/yohamta/donburi/filter"
+)
+
+// CellSize is the width and height of a grid cell in pixels.
+const CellSize = 64
+
+// Slack is how far, in pixels, an entity may move after it was inserted and still be found by queries.
+const Slack = 8
+
+// Grid is a resource that buckets entities by the cell their top-left corner lies in. It is rebuilt every tick,
+// so it can hold entities that have been removed since; callers check that the entities they get are still valid.
+type Grid struct {
+	// Width and Height are the size of the grid in cells.
+	Width  int
+	Height int
+	cells  [][]donburi.Entity
+	// maxW and maxH are the largest entity size inserted since the grid was cleared. Queries look that far
+	// up and to the left, so entities are found by any part of their bounds, not just their corner.
+	maxW, maxH float64
+}
+
+var GridRes = donburi.NewComponentType[Grid]()
+
+// NewGrid creates an empty grid covering a map of the given size in pixels.
+func NewGrid(mapWidth, mapHeight int) *Grid {
+	width := max(1, (mapWidth+CellSize-1)/CellSize)
+	height := max(1, (mapHeight+CellSize-1)/CellSize)
+	return &Grid{
+		Width:  width,
+		Height: height,
+		cells:  make([][]donburi.Entity, width*height),
+	}
+}
+
+// GetGrid gets the spatial grid from the world.
+func GetGrid(w donburi.World) *Grid {
+	entry, _ := donburi.NewQuery(filter.Contains(GridRes)).First(w)
+	return GridRes.Get(entry)
+}
+
+// Clear removes every entity from the grid, keeping the memory of its cells.
+func (g *Grid) Clear() {
+	for i := range g.cells {
+		g.cells[i] = g.cells[i][:0]
+	}
+	g.maxW, g.maxH = 0, 0
+}
+
+// Insert adds an entity with its top-left corner at (x, y) and the given size.
+func (g *Grid) Insert(e donburi.Entity, x, y, width, height float64) {
+	cx, cy := g.cell(x, y)
+	g.cells[cy*g.Width+cx] = append(g.cells[cy*g.Width+cx], e)
+	g.maxW = math.Max(g.maxW, width)
+	g.maxH = math.Max(g.maxH, height)
+}
+
+// Query calls fn for every entity whose bounds may overlap the rectangle from (x0, y0) to (x1, y1). It can also
+// report entities just outside the rectangle, so callers do their own exact check. Entities are visited cell by
+// cell, row by row, in the order they were inserted, which is the same on every machine.
+func (g *Grid) Query(x0, y0, x1, y1 float64, fn func(donburi.Entity)) {
+	minX, minY := g.cell(x0-g.maxW-Slack, y0-g.maxH-Slack)
+	maxX, maxY := g.cell(x1+Slack, y1+Slack)
+	for cy := minY; cy <= maxY; cy++ {
+		for cx := minX; cx <= maxX; cx++ {
+			for _, e := range g.cells[cy*g.Width+cx] {
+				fn(e)
+			}
+		}
+	}
+}
+
+// Nearest returns the entity closest to (x, y) and no further than maxDist, or donburi.Null if there is none.
+// dist measures how far an entity's top-left corner is from (x, y), or returns false for entities that don't count.
+// Cells are searched in rings of increasing size and the search stops once no closer entity can be found.
+func (g *Grid) Nearest(x, y, maxDist float64, dist func(donburi.Entity) (float64, bool)) donburi.Entity {
+	nearest := donburi.Null
+	best := maxDist
+	cx, cy := g.cell(x, y)
+	for r := 0; r <= max(g.Width, g.Height); r++ {
+		// Every entity in ring r lies more than r-1 cells away, give or take how far it moved since it was inserted.
+		if float64(r-1)*CellSize-Slack > best {
+			break
+		}
+		for ry := cy - r; ry <= cy+r; ry++ {
+			if ry < 0 || ry >= g.Height {
+				continue
+			}
+			for rx := cx - r; rx <= cx+r; rx++ {
+				// Only the edge of the ring; the inside was searched by the smaller rings.
+				if rx < 0 || rx >= g.Width || (ry != cy-r && ry != cy+r && rx != cx-r && rx != cx+r) {
+					continue
+				}
+				for _, e := range g.cells[ry*g.Width+rx] {
+					if d, ok := dist(e); ok && d <= best && (nearest == donburi.Null || d < best) {
+						nearest, best = e, d
+					}
+				}
+			}
+		}
+	}
+	return nearest
+}
+
+// cell returns the cell a world position lies in. Positions outside the map are clamped to the cells on its edge.
+func (g *Grid) cell(x, y float64) (int, int) {
+	cx := min(max(int(math.Floor(x/CellSize)), 0), g.Width-1)
+	cy := min(max(int(math.Floor(y/CellSize)), 0), g.Height-1)
+	return cx, cy
+}
@@ -0,0 +1,266 @@
+package systems
+
+import (
+	"math"
+
+	"github.com/gfeyer/ebit/internal/command"
+	"github.com/gfeyer/ebit/internal/components"
+	"github.com/gfeyer/ebit/internal/terrain"
+	"github.com/yohamta/donburi"
+	"github.com/yohamta/donburi/ecs"
+	"github.com/yohamta/donburi/filter"
+)
+
+const (
+	// aiThinkInterval is the number of ticks between two rounds of AI decisions.
+	aiThinkInterval = 60
+	// aiHarvestersPerRefinery is how many harvesters the AI keeps per refinery.
+	aiHarvestersPerRefinery = 2
+	// aiMaxRefineries is the number of refineries the AI expands to.
+	aiMaxRefineries = 2
+	// aiAttackWaveSize is the number of idle combat units the AI gathers before attacking.
+	aiAttackWaveSize = 6
+	// aiMoneyReserve is kept back when training combat units so the economy can still grow.
+	aiMoneyReserve = 300
+	// aiPowerReserve is the spare power below which the AI builds another windtrap.
+	aiPowerReserve = 20
+	// aiBuildSiteRings is how many tiles away from its base center the AI looks for a building site.
+	aiBuildSiteRings = 12
+)
+
+var (
+	// qAIPlayers retrieves all players controlled by the computer.
+	qAIPlayers = donburi.NewQuery(filter.Contains(components.PlayerRes, components.AIRes))
+	// qOwnedUnits retrieves all units that belong to a faction.
+	qOwnedUnits = donburi.NewQuery(filter.Contains(components.Position, components.UnitRes, components.OwnerRes))
+)
+
+// aiBase is a snapshot of everything a computer player owns, gathered once per decision round.
+type aiBase struct {
+	refineries []*donburi.Entry
+	barracks   []*donburi.Entry
+	harvesters []*donburi.Entry
+	army       []*donburi.Entry
+	// buildings counts every building the AI owns; new buildings can be placed next to any of them.
+	buildings int
+	// centerX and centerY is the position the AI builds around: its first building.
+	centerX, centerY float64
+	// money is what is left to spend in this round. Commands are only applied later in the tick,
+	// so the AI keeps track of what its orders will cost itself.
+	money int
+}
+
+// UpdateAI runs the decision making of every computer-controlled player. Every aiThinkInterval ticks
+// the AI sends idle harvesters to spice, places new buildings, trains units and launches attacks by
+// issuing the same commands as the local player.
+func UpdateAI(ecs *ecs.ECS) {
+	qAIPlayers.Each(ecs.World, func(entry *donburi.Entry) {
+		ai := components.AIRes.Get(entry)
+		if ai.ThinkTimer > 0 {
+			ai.ThinkTimer--
+			return
+		}
+		ai.ThinkTimer = aiThinkInterval
+
+		player := components.PlayerRes.Get(entry)
+		base := gatherAIBase(ecs.World, player.Faction)
+		base.money = player.Money
+
+		aiHarvest(ecs.World, player, base)
+		aiBuild(ecs.World, player, base)
+		aiTrain(ecs.World, player, ai, base)
+		aiAttack(ecs.World, player, base)
+	})
+}
+
+// gatherAIBase collects the buildings and units owned by a faction.
+func gatherAIBase(w donburi.World, faction components.Faction) *aiBase {
+	base := &aiBase{}
+	foundCenter := false
+	qBuildings.Each(w, func(entry *donburi.Entry) {
+		if !IsOwnedBy(entry, faction) {
+			return
+		}
+		base.buildings++
+		if !foundCenter {
+			p := components.Position.Get(entry)
+			base.centerX, base.centerY = p.X, p.Y
+			foundCenter = true
+		}
+		if entry.HasComponent(components.RefineryRes) {
+			base.refineries = append(base.refineries, entry)
+		}
+		if entry.HasComponent(components.BarracksRes) {
+			base.barracks = append(base.barracks, entry)
+		}
+	})
+	qOwnedUnits.Each(w, func(entry *donburi.Entry) {
+		if !IsOwnedBy(entry, faction) {
+			return
+		}
+		if entry.HasComponent(components.HarvesterRes) {
+			base.harvesters = append(base.harvesters, entry)
+		}
+		if entry.HasComponent(components.WeaponRes) {
+			base.army = append(base.army, entry)
+		}
+	})
+	return base
+}
+
+// aiHarvest sends every idle, empty harvester to the spice field closest to it.
+func aiHarvest(w donburi.World, player *components.Player, base *aiBase) {
+	for _, entry := range base.harvesters {
+		harvester := components.HarvesterRes.Get(entry)
+		if harvester.State != components.StateIdle || harvester.CarriedAmount > 0 || harvester.TargetSpice != 0 {
+			continue
+		}
+
+		p := components.Position.Get(entry)
+		closestSpice := nearestEntity(w, p.X, p.Y, math.MaxFloat64, func(spiceEntry *donburi.Entry) bool {
+			return spiceEntry.HasComponent(components.SpiceRes) && spiceEntry.HasComponent(components.SpiceAmountRes)
+		})
+		if closestSpice != nil {
+			command.Issue(w, command.Command{Kind: command.Harvest, Faction: player.Faction, Units: []donburi.Entity{entry.Entity()}, Target: closestSpice.Entity()})
+		}
+	}
+}
+
+// aiBuild keeps enough windtraps for its power, constructs a barracks and then expands to more refineries while money allows.
+// Finished constructions are placed on the first free site around the base.
+func aiBuild(w donburi.World, player *components.Player, base *aiBase) {
+	// Without any building left there is nothing to build around.
+	if base.buildings == 0 {
+		return
+	}
+
+	construction := getConstruction(w, player.Faction)
+	if construction == nil {
+		return
+	}
+	if construction.Ready {
+		if x, y, ok := aiFindBuildSite(w, player.Faction, construction.Type, base); ok {
+			command.Issue(w, command.Command{Kind: command.PlaceBuilding, Faction: player.Faction, X: x, Y: y})
+		}
+		return
+	}
+	if construction.Active {
+		return
+	}
+
+	var btype components.BuildingType
+	var reserve int
+	switch {
+	case player.Power.Produced-player.Power.Consumed < aiPowerReserve:
+		btype = components.BuildingWindtrap
+	case len(base.barracks) == 0 && !buildingLocked(w, player.Faction, components.BuildingBarracks):
+		btype = components.BuildingBarracks
+	case len(base.refineries) < aiMaxRefineries:
+		btype, reserve = components.BuildingRefinery, aiMoneyReserve
+	default:
+		return
+	}
+
+	buildInfo := findBuildInfo(w, btype)
+	if buildInfo == nil || buildingLocked(w, player.Faction, btype) || base.money < buildInfo.Cost+reserve {
+		return
+	}
+	command.Issue(w, command.Command{Kind: command.StartConstruction, Faction: player.Faction, BuildingType: btype})
+	base.money -= buildInfo.Cost
+}
+
+// aiFindBuildSite searches square rings of tiles of increasing size around the base for the first valid building site.
+// New buildings must be adjacent to the base, so the rings are searched a tile at a time.
+func aiFindBuildSite(w donburi.World, faction components.Faction, btype components.BuildingType, base *aiBase) (float64, float64, bool) {
+	ts := float64(terrain.GetTerrain(w).TileSize)
+	cx, cy := snapToTile(w, base.centerX, base.centerY)
+	for r := 1; r <= aiBuildSiteRings; r++ {
+		for dy := -r; dy <= r; dy++ {
+			for dx := -r; dx <= r; dx++ {
+				// Only the edge of the ring; the inside was searched by the smaller rings.
+				if dx != -r && dx != r && dy != -r && dy != r {
+					continue
+				}
+				x, y := cx+float64(dx)*ts, cy+float64(dy)*ts
+				if canPlaceBuilding(w, faction, btype, x, y) {
+					return x, y, true
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// aiTrain keeps every refinery staffed with harvesters and spends the remaining money on combat units.
+// Each building only gets a new order once its queue is empty, so money isn't tied up in long queues.
+func aiTrain(w donburi.World, player *components.Player, ai *components.AI, base *aiBase) {
+	harvesters := len(base.harvesters)
+	for _, refinery := range base.refineries {
+		harvesters += len(components.ProductionRes.Get(refinery).Queue)
+	}
+	if len(base.refineries) > 0 && harvesters < aiHarvestersPerRefinery*len(base.refineries) {
+		for _, refinery := range base.refineries {
+			if len(components.ProductionRes.Get(refinery).Queue) > 0 {
+				continue
+			}
+			if info := findUnitInfo(w, components.Harvester); info != nil && base.money >= info.Cost {
+				command.Issue(w, command.Command{Kind: command.TrainUnit, Faction: player.Faction, Target: refinery.Entity(), UnitType: info.Type})
+				base.money -= info.Cost
+			}
+			break
+		}
+		return
+	}
+
+	for _, barracks := range base.barracks {
+		if len(components.ProductionRes.Get(barracks).Queue) > 0 {
+			continue
+		}
+		utype := components.Trike
+		if ai.NextUnit%2 == 1 && !unitLocked(w, player.Faction, components.Quad) {
+			utype = components.Quad
+		}
+		info := findUnitInfo(w, utype)
+		if info == nil || base.money < info.Cost+aiMoneyReserve {
+			return
+		}
+		command.Issue(w, command.Command{Kind: command.TrainUnit, Faction: player.Faction, Target: barracks.Entity(), UnitType: utype})
+		base.money -= info.Cost
+		ai.NextUnit++
+	}
+}
+
+// aiAttack sends the idle army against the enemy building closest to the base once enough units have gathered.
+func aiAttack(w donburi.World, player *components.Player, base *aiBase) {
+	var idle []*donburi.Entry
+	for _, entry := range base.army {
+		t := components.TargetRes.Get(entry)
+		if components.AttackRes.Get(entry).Target == 0 && t.X == 0 && t.Y == 0 {
+			idle = append(idle, entry)
+		}
+	}
+	if len(idle) < aiAttackWaveSize {
+		return
+	}
+
+	// Prefer enemy buildings; fall back to any enemy unit once no building is left.
+	isEnemy := func(entry *donburi.Entry) bool {
+		return isAttackable(entry) && entry.HasComponent(components.OwnerRes) && !IsOwnedBy(entry, player.Faction)
+	}
+	target := nearestEntity(w, base.centerX, base.centerY, math.MaxFloat64, func(entry *donburi.Entry) bool {
+		return isEnemy(entry) && !entry.HasComponent(components.UnitRes)
+	})
+	if target == nil {
+		target = nearestEntity(w, base.centerX, base.centerY, math.MaxFloat64, func(entry *donburi.Entry) bool {
+			return isEnemy(entry) && entry.HasComponent(components.UnitRes)
+		})
+	}
+	if target == nil {
+		return
+	}
+	units := make([]donburi.Entity, len(idle))
+	for i, entry := range idle {
+		units[i] = entry.Entity()
+	}
+	command.Issue(w, command.Command{Kind: command.Attack, Faction: player.Faction, Units: units, Target: target.Entity()})
+}
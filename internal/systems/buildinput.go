@@ -2,13 +2,12 @@
 package systems
 
 import (
-	"math/rand"
-
 	"github.com/gfeyer/ebit/internal/camera"
+	"github.com/gfeyer/ebit/internal/command"
 	"github.com/gfeyer/ebit/internal/components"
 	"github.com/gfeyer/ebit/internal/factory"
+	"github.com/gfeyer/ebit/internal/input"
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/yohamta/donburi"
 	"github.com/yohamta/donburi/ecs"
 	"github.com/yohamta/donburi/filter"
@@ -19,22 +18,12 @@ var (
 	PlacementQuery = donburi.NewQuery(filter.Contains(components.PlacementRes))
 	// PlayerQuery retrieves the player's entity, used for accessing resources like money.
 	PlayerQuery = donburi.NewQuery(filter.Contains(components.PlayerRes))
-
-	// SelectableBuildingQuery retrieves all building entities that can be selected by the player.
-	SelectableBuildingQuery = donburi.NewQuery(
-		filter.And(
-			filter.Contains(components.SelectableRes),
-			filter.Or(
-				filter.Contains(components.RefineryRes),
-				filter.Contains(components.BarracksRes),
-			),
-		),
-	)
 )
 
 // UpdateBuildInput handles all user input related to building placement and unit creation.
 // It's called every frame to check for mouse clicks on the world or the build menu.
 func UpdateBuildInput(ecs *ecs.ECS) {
+	in := input.GetInput(ecs.World)
 	placementEntry, ok := PlacementQuery.First(ecs.World)
 	if !ok {
 		return
@@ -44,57 +33,49 @@ func UpdateBuildInput(ecs *ecs.ECS) {
 	// If the player is currently in the process of placing a building.
 	if placement.IsPlacing {
 		// Cancel placement with a right-click or by pressing the Escape key.
-		if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) || inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		if in.IsMouseButtonJustPressed(ebiten.MouseButtonRight) || in.IsKeyJustPressed(ebiten.KeyEscape) {
 			placement.IsPlacing = false
 			return
 		}
 
 		// Confirm building placement with a left-click.
-		if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
-			playerEntry, ok := PlayerQuery.First(ecs.World)
-			if !ok {
-				return
-			}
-			player := components.PlayerRes.Get(playerEntry)
-
-			// Check for sufficient funds
-			if player.Money < placement.Cost {
-				// Not enough money, exit placement mode
-				placement.IsPlacing = false
+		if in.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+			player := LocalPlayer(ecs.World)
+			if player == nil {
 				return
 			}
 
-			// Deduct cost and place building
-			player.Money -= placement.Cost
-
 			cameraEntry, ok := camera.CameraQuery.First(ecs.World)
 			if !ok {
 				return
 			}
 			cam := camera.CameraRes.Get(cameraEntry)
-			mx, my := ebiten.CursorPosition()
+			mx, my := in.CursorPosition()
 			wx, wy := cam.ScreenToWorld(float64(mx), float64(my))
 
-			// Create the building
-			switch placement.BuildingType {
-			case components.BuildingRefinery:
-				factory.CreateRefinery(ecs.World, wx, wy)
-			case components.BuildingBarracks:
-				factory.CreateBarracks(ecs.World, wx, wy)
+			// Place the finished building; it was already paid for when its construction started.
+			// On an invalid site nothing happens and the player stays in placement mode.
+			x, y := snapToTile(ecs.World, wx, wy)
+			if isExplored(ecs.World, placement.BuildingType, x, y) && canPlaceBuilding(ecs.World, player.Faction, placement.BuildingType, x, y) {
+				command.Issue(ecs.World, command.Command{Kind: command.PlaceBuilding, Faction: player.Faction, X: x, Y: y})
+				placement.IsPlacing = false
 			}
-
-			// Exit placement mode
-			placement.IsPlacing = false
 		}
 		return
 	}
 
+	// A right-click on a menu icon cancels the last order of that kind and refunds it.
+	if in.IsMouseButtonJustPressed(ebiten.MouseButtonRight) {
+		mx, my := in.CursorPosition()
+		checkBuildMenuClick(ecs, mx, my, true)
+	}
+
 	// If not in placement mode, check for clicks on the build menu or for selecting buildings in the world.
-	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
-		mx, my := ebiten.CursorPosition()
+	if in.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		mx, my := in.CursorPosition()
 
 		// First, check for clicks on the build menu
-		if checkBuildMenuClick(ecs, mx, my) {
+		if checkBuildMenuClick(ecs, mx, my, false) {
 			return // Click was handled by the menu
 		}
 
@@ -111,16 +92,14 @@ func UpdateBuildInput(ecs *ecs.ECS) {
 			}
 		})
 
-		// Check if a selectable building was clicked
+		// Check if one of the player's buildings was clicked
+		local := LocalPlayer(ecs.World)
 		var clickedBuilding *donburi.Entry
-		SelectableBuildingQuery.Each(ecs.World, func(entry *donburi.Entry) {
-			p := components.Position.Get(entry)
-			sprite := components.Sprite.Get(entry)
-			bounds := (*sprite).Bounds()
-
-			if wx >= p.X && wx < p.X+float64(bounds.Dx()) && wy >= p.Y && wy < p.Y+float64(bounds.Dy()) {
-				clickedBuilding = entry
+		entitiesAt(ecs.World, wx, wy, func(entry *donburi.Entry) {
+			if local == nil || !entry.HasComponent(components.BuildingRes) || !entry.HasComponent(components.SelectableRes) || !IsOwnedBy(entry, local.Faction) {
+				return
 			}
+			clickedBuilding = entry
 		})
 
 		// If a building was clicked, select it
@@ -133,7 +112,8 @@ func UpdateBuildInput(ecs *ecs.ECS) {
 
 // checkBuildMenuClick determines if a mouse click at screen coordinates (mx, my) has occurred on a build menu icon.
 // It returns true if a menu item was clicked, handling the corresponding action, and false otherwise.
-func checkBuildMenuClick(ecs *ecs.ECS, mx, my int) bool {
+// A regular click queues the item; with cancel set, the click cancels it instead.
+func checkBuildMenuClick(ecs *ecs.ECS, mx, my int, cancel bool) bool {
 	placementEntry, ok := PlacementQuery.First(ecs.World)
 	if !ok {
 		return false
@@ -150,7 +130,8 @@ func checkBuildMenuClick(ecs *ecs.ECS, mx, my int) bool {
 	menuY := minimap.Y + minimap.Height + 10
 	padding := 5
 	iconWidth := (minimap.Width - padding) / 2
-	rowHeight := 64 + padding // from game.go
+	iconHeight := 64 // from game.go
+	rowHeight := iconHeight + padding
 
 	clickedOnMenu := false
 
@@ -158,18 +139,16 @@ func checkBuildMenuClick(ecs *ecs.ECS, mx, my int) bool {
 	var selectedBuilding *donburi.Entry
 	SelectedBuildingQuery.Each(ecs.World, func(entry *donburi.Entry) {
 		if components.SelectableRes.Get(entry).Selected {
-			selectedBuilding = entry
+			// Only buildings that train units have a unit menu; anything else leaves the building menu in place.
+			if btype, ok := factory.BuildingTypeOf(entry); ok && trainsUnits(ecs.World, btype) {
+				selectedBuilding = entry
+			}
 		}
 	})
 
 	// If a building is selected, display the menu for units that can be built from it.
 	if selectedBuilding != nil {
-		var buildingType components.BuildingType
-		if selectedBuilding.HasComponent(components.RefineryRes) {
-			buildingType = components.BuildingRefinery
-		} else if selectedBuilding.HasComponent(components.BarracksRes) {
-			buildingType = components.BuildingBarracks
-		}
+		buildingType := components.BuildingRes.Get(selectedBuilding).Type
 
 		i := 0
 		UnitMenuQuery.Each(ecs.World, func(entry *donburi.Entry) {
@@ -182,31 +161,22 @@ func checkBuildMenuClick(ecs *ecs.ECS, mx, my int) bool {
 			row := i / 2
 			iconX := menuX + col*(iconWidth+padding)
 			iconY := menuY + row*rowHeight
-			actualIconWidth := unitInfo.Icon.Bounds().Dx()
-			actualIconHeight := unitInfo.Icon.Bounds().Dy()
 
-			if mx >= iconX && mx < iconX+actualIconWidth && my >= iconY && my < iconY+actualIconHeight {
-				// Handle unit creation
-				playerEntry, ok := PlayerQuery.First(ecs.World)
-				if !ok {
-					return
+			if mx >= iconX && mx < iconX+iconWidth && my >= iconY && my < iconY+iconHeight {
+				// Queue the unit in the building's production, or take it back out when cancelling.
+				// Locked units can't be queued, but what is already queued can still be cancelled.
+				faction := components.OwnerRes.Get(selectedBuilding).Faction
+				kind := command.TrainUnit
+				if cancel {
+					kind = command.CancelUnit
 				}
-				player := components.PlayerRes.Get(playerEntry)
-
-				if player.Money >= unitInfo.Cost {
-					player.Money -= unitInfo.Cost
-					buildingPos := components.Position.Get(selectedBuilding)
-					spawnX := buildingPos.X + float64(rand.Intn(64)) + 32 // Spawn to the right of the building
-					spawnY := buildingPos.Y + float64(rand.Intn(64)) + 32
-
-					switch unitInfo.Type {
-					case components.Harvester:
-						factory.CreateHarvester(ecs.World, spawnX, spawnY)
-					case components.Trike:
-						factory.CreateTrike(ecs.World, spawnX, spawnY)
-					case components.Quad:
-						factory.CreateQuad(ecs.World, spawnX, spawnY)
-					}
+				if cancel || !unitLocked(ecs.World, faction, unitInfo.Type) {
+					command.Issue(ecs.World, command.Command{
+						Kind:     kind,
+						Faction:  faction,
+						Target:   selectedBuilding.Entity(),
+						UnitType: unitInfo.Type,
+					})
 				}
 				clickedOnMenu = true
 			}
@@ -221,16 +191,30 @@ func checkBuildMenuClick(ecs *ecs.ECS, mx, my int) bool {
 			row := i / 2
 			iconX := menuX + col*(iconWidth+padding)
 			iconY := menuY + row*rowHeight
-			actualIconWidth := buildInfo.Icon.Bounds().Dx()
-			actualIconHeight := buildInfo.Icon.Bounds().Dy()
-
-			if mx >= iconX && mx < iconX+actualIconWidth && my >= iconY && my < iconY+actualIconHeight {
-				// Clicked on this build option
-				placement.IsPlacing = true
-				placement.BuildingType = buildInfo.Type
-				placement.Icon = buildInfo.Icon
-				placement.Cost = buildInfo.Cost
+
+			if mx >= iconX && mx < iconX+iconWidth && my >= iconY && my < iconY+iconHeight {
+				// Clicked on this build option: start constructing it, place it once it's ready, or cancel it.
 				clickedOnMenu = true
+				local := LocalPlayer(ecs.World)
+				if local == nil {
+					return
+				}
+				construction := getConstruction(ecs.World, local.Faction)
+				switch {
+				case construction == nil:
+				case cancel:
+					if construction.Active && construction.Type == buildInfo.Type {
+						command.Issue(ecs.World, command.Command{Kind: command.CancelConstruction, Faction: local.Faction})
+					}
+				case buildingLocked(ecs.World, local.Faction, buildInfo.Type):
+					// Locked buildings can't be started or placed.
+				case !construction.Active:
+					command.Issue(ecs.World, command.Command{Kind: command.StartConstruction, Faction: local.Faction, BuildingType: buildInfo.Type})
+				case construction.Ready && construction.Type == buildInfo.Type:
+					placement.IsPlacing = true
+					placement.BuildingType = buildInfo.Type
+					placement.Icon = buildInfo.Icon
+				}
 			}
 			i++
 		})
@@ -1,11 +1,18 @@
 package systems
 
 import (
+	"fmt"
+	"image/color"
+
 	"github.com/gfeyer/ebit/internal/components"
+	"github.com/gfeyer/ebit/internal/factory"
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
 	"github.com/yohamta/donburi"
 	"github.com/yohamta/donburi/ecs"
 	"github.com/yohamta/donburi/filter"
+	"golang.org/x/image/font/basicfont"
 )
 
 var (
@@ -35,8 +42,8 @@ func DrawBuildMenu(ecs *ecs.ECS, screen *ebiten.Image) {
 	var selectedBuilding *donburi.Entry
 	SelectedBuildingQuery.Each(ecs.World, func(entry *donburi.Entry) {
 		if components.SelectableRes.Get(entry).Selected {
-			// Ensure the selected entity is a building before changing the menu.
-			if entry.HasComponent(components.RefineryRes) || entry.HasComponent(components.BarracksRes) {
+			// Ensure the selected entity is a building that trains units before changing the menu.
+			if btype, ok := factory.BuildingTypeOf(entry); ok && trainsUnits(ecs.World, btype) {
 				selectedBuilding = entry
 			}
 		}
@@ -45,13 +52,7 @@ func DrawBuildMenu(ecs *ecs.ECS, screen *ebiten.Image) {
 	i := 0
 	if selectedBuilding != nil {
 		// A building is selected, so draw the menu for available units.
-		// A building is selected, draw the unit menu
-		var buildingType components.BuildingType
-		if selectedBuilding.HasComponent(components.RefineryRes) {
-			buildingType = components.BuildingRefinery
-		} else if selectedBuilding.HasComponent(components.BarracksRes) {
-			buildingType = components.BuildingBarracks
-		}
+		buildingType := components.BuildingRes.Get(selectedBuilding).Type
 
 		// Iterate through all available units and draw the ones that can be built from the selected building.
 		UnitMenuQuery.Each(ecs.World, func(entry *donburi.Entry) {
@@ -66,15 +67,34 @@ func DrawBuildMenu(ecs *ecs.ECS, screen *ebiten.Image) {
 			iconX := menuX + col*(iconWidth+padding)
 			iconY := menuY + row*rowHeight
 
-			opts := &ebiten.DrawImageOptions{}
-			opts.GeoM.Translate(float64(iconX), float64(iconY))
-			screen.DrawImage(unitInfo.Icon, opts)
+			// Grey out a unit whose prerequisites are missing and name the first missing building.
+			faction := components.OwnerRes.Get(selectedBuilding).Faction
+			missing, locked := missingPrerequisite(ecs.World, faction, unitInfo.Prerequisites)
+			drawMenuIcon(screen, unitInfo.Icon, iconX, iconY, locked)
+			if locked {
+				drawLockedLabel(screen, unitInfo.Icon, iconX, iconY, buildingName(ecs.World, missing))
+			}
+
+			// Show how far along the unit at the head of the queue is and how many are queued.
+			production := components.ProductionRes.Get(selectedBuilding)
+			if count := queuedCount(production, unitInfo.Type); count > 0 {
+				progress := 0.0
+				if head := production.Queue[0]; head.Type == unitInfo.Type {
+					progress = float64(production.Progress) / float64(head.BuildTime)
+				}
+				drawProgressOverlay(screen, unitInfo.Icon, iconX, iconY, progress, fmt.Sprintf("x%d", count))
+			}
 
 			i++
 		})
 	} else {
 		// No building is selected, so draw the menu for constructing buildings.
 		// Iterate through the available buildings and draw their icons.
+		var construction *components.Construction
+		local := LocalPlayer(ecs.World)
+		if local != nil {
+			construction = getConstruction(ecs.World, local.Faction)
+		}
 		BuildMenuQuery.Each(ecs.World, func(entry *donburi.Entry) {
 			buildInfo := components.BuildInfoRes.Get(entry)
 
@@ -84,11 +104,63 @@ func DrawBuildMenu(ecs *ecs.ECS, screen *ebiten.Image) {
 			iconX := menuX + col*(iconWidth+padding)
 			iconY := menuY + row*rowHeight
 
-			opts := &ebiten.DrawImageOptions{}
-			opts.GeoM.Translate(float64(iconX), float64(iconY))
-			screen.DrawImage(buildInfo.Icon, opts)
+			// Grey out a building whose prerequisites or Construction Yard are missing and name the first missing building.
+			var missing components.BuildingType
+			locked := false
+			if local != nil {
+				missing, locked = missingForConstruction(ecs.World, local.Faction, buildInfo)
+			}
+			drawMenuIcon(screen, buildInfo.Icon, iconX, iconY, locked)
+			if locked {
+				drawLockedLabel(screen, buildInfo.Icon, iconX, iconY, buildingName(ecs.World, missing))
+			}
+
+			// Show the construction progress, or that the building is ready to be placed.
+			if construction != nil && construction.Active && construction.Type == buildInfo.Type {
+				if construction.Ready {
+					drawProgressOverlay(screen, buildInfo.Icon, iconX, iconY, 1, "Ready")
+				} else {
+					drawProgressOverlay(screen, buildInfo.Icon, iconX, iconY, float64(construction.Progress)/float64(construction.BuildTime), "")
+				}
+			}
 
 			i++
 		})
 	}
 }
+
+// drawProgressOverlay darkens the part of a menu icon that is still to be built, filling it from the bottom
+// up as progress goes from 0 to 1, and writes a short label in the icon's bottom-right corner.
+func drawProgressOverlay(screen *ebiten.Image, icon *ebiten.Image, x, y int, progress float64, label string) {
+	w := float32(icon.Bounds().Dx())
+	h := float32(icon.Bounds().Dy())
+	remaining := h * float32(1-min(max(progress, 0), 1))
+	vector.DrawFilledRect(screen, float32(x), float32(y), w, remaining, color.RGBA{A: 160}, false)
+	vector.StrokeRect(screen, float32(x), float32(y), w, h, 1, color.RGBA{R: 255, G: 220, B: 64, A: 255}, false)
+
+	if label != "" {
+		bounds := text.BoundString(basicfont.Face7x13, label)
+		text.Draw(screen, label, basicfont.Face7x13, x+int(w)-bounds.Dx()-4, y+int(h)-4, color.White)
+	}
+}
+
+// drawMenuIcon draws a menu icon at a screen position, greyed out when the entry is locked.
+func drawMenuIcon(screen *ebiten.Image, icon *ebiten.Image, x, y int, locked bool) {
+	opts := &ebiten.DrawImageOptions{}
+	opts.GeoM.Translate(float64(x), float64(y))
+	if locked {
+		opts.ColorScale.Scale(0.5, 0.5, 0.5, 1)
+	}
+	screen.DrawImage(icon, opts)
+}
+
+// drawLockedLabel writes which building a locked menu entry still needs across the bottom of its icon,
+// below the name and cost.
+func drawLockedLabel(screen *ebiten.Image, icon *ebiten.Image, x, y int, name string) {
+	w := float32(icon.Bounds().Dx())
+	h := icon.Bounds().Dy()
+	labelColor := color.RGBA{R: 255, G: 96, B: 96, A: 255}
+	vector.DrawFilledRect(screen, float32(x), float32(y+h-30), w, 30, color.RGBA{A: 180}, false)
+	text.Draw(screen, "Needs:", basicfont.Face7x13, x+3, y+h-18, labelColor)
+	text.Draw(screen, name, basicfont.Face7x13, x+3, y+h-4, labelColor)
+}
@@ -9,20 +9,22 @@ import (
 	"github.com/yohamta/donburi/filter"
 )
 
-// qCollision is a query that retrieves all entities with Position, UnitRes, and Sprite components, which are necessary for collision detection.
-var qCollision = donburi.NewQuery(filter.Contains(components.Position, components.UnitRes, components.Sprite))
+// qCollision is a query that retrieves all entities with Position, UnitRes, and Size components, which are necessary for collision detection.
+var qCollision = donburi.NewQuery(filter.Contains(components.Position, components.UnitRes, components.SizeRes))
 
 // ResolveCollisions handles the collision detection and resolution between units.
-// It iterates through all pairs of units and pushes them apart if they overlap.
+// It compares every unit with the units near it in the spatial grid and pushes them apart if they overlap.
 func ResolveCollisions(ecs *ecs.ECS) {
 	// Iterate over each entity that can collide.
 	qCollision.Each(ecs.World, func(entry *donburi.Entry) {
 		p1 := components.Position.Get(entry)
 
-		// Compare it with every other entity that can collide.
-		qCollision.Each(ecs.World, func(other *donburi.Entry) {
-			// Don't check for collision with itself.
-			if entry.Entity() == other.Entity() {
+		// Compare it with every other unit close enough to collide: the unit's footprint grown by its own size
+		// on every side reaches any unit it can overlap.
+		size := components.SizeRes.Get(entry).W
+		entitiesNear(ecs.World, p1.X-size, p1.Y-size, p1.X+2*size, p1.Y+2*size, func(other *donburi.Entry) {
+			// Don't check for collision with itself, or with buildings and spice.
+			if entry.Entity() == other.Entity() || !other.HasComponent(components.UnitRes) {
 				return
 			}
 
@@ -33,11 +35,9 @@ func ResolveCollisions(ecs *ecs.ECS) {
 			dy := p1.Y - p2.Y
 			dist := math.Sqrt(dx*dx + dy*dy)
 
-			// Calculate the radii of the two entities based on their sprite size.
-			s1 := components.Sprite.Get(entry)
-			s2 := components.Sprite.Get(other)
-			radius1 := float64((*s1).Bounds().Dx()) / 2
-			radius2 := float64((*s2).Bounds().Dx()) / 2
+			// Calculate the radii of the two entities based on their size.
+			radius1 := components.SizeRes.Get(entry).W / 2
+			radius2 := components.SizeRes.Get(other).W / 2
 			// The required distance is half the sum of the radii, allowing for 50% overlap.
 			requiredDist := (radius1 + radius2) * 0.5 // Allow 50% overlap
 
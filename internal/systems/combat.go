@@ -0,0 +1,181 @@
+package systems
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/gfeyer/ebit/internal/camera"
+	"github.com/gfeyer/ebit/internal/components"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/yohamta/donburi"
+	"github.com/yohamta/donburi/ecs"
+	"github.com/yohamta/donburi/filter"
+)
+
+var (
+	// qAttackers retrieves all units armed with a weapon.
+	qAttackers = donburi.NewQuery(filter.Contains(components.Position, components.Velocity, components.TargetRes, components.WeaponRes, components.AttackRes, components.OwnerRes))
+	// qTurrets retrieves all defensive structures.
+	qTurrets = donburi.NewQuery(filter.Contains(components.Position, components.BuildingRes, components.WeaponRes, components.AttackRes, components.OwnerRes))
+	// qArmed retrieves every unit and building with a weapon.
+	qArmed = donburi.NewQuery(filter.Contains(components.Position, components.WeaponRes, components.AttackRes))
+	// qMortal retrieves all entities that have health and can be destroyed.
+	qMortal = donburi.NewQuery(filter.Contains(components.HealthRes))
+)
+
+// UpdateCombat moves armed units into range of their attack target and fires at it once the weapon has reloaded.
+func UpdateCombat(ecs *ecs.ECS) {
+	qAttackers.Each(ecs.World, func(entry *donburi.Entry) {
+		weapon := components.WeaponRes.Get(entry)
+		if weapon.Reload > 0 {
+			weapon.Reload--
+		}
+		if weapon.Flash > 0 {
+			weapon.Flash--
+		}
+
+		attack := components.AttackRes.Get(entry)
+		t := components.TargetRes.Get(entry)
+		if attack.Target == 0 {
+			// Units standing still engage the closest enemy that comes within range on their own.
+			if t.X != 0 || t.Y != 0 {
+				return
+			}
+			enemy := findNearestEnemy(ecs.World, entry, weapon.Range)
+			if enemy == nil {
+				return
+			}
+			attack.Target = enemy.Entity()
+		}
+
+		// Forget targets that have been destroyed.
+		if !ecs.World.Valid(attack.Target) {
+			attack.Target = 0
+			return
+		}
+		targetEntry := ecs.World.Entry(attack.Target)
+
+		p := components.Position.Get(entry)
+		tp := components.Position.Get(targetEntry)
+		dx := tp.X - p.X
+		dy := tp.Y - p.Y
+		dist := math.Sqrt(dx*dx + dy*dy)
+
+		// Chase the target until it is within weapon range.
+		if dist > weapon.Range {
+			*t = components.Target{X: tp.X, Y: tp.Y}
+			return
+		}
+
+		// In range: stop moving and fire whenever the weapon is ready.
+		if t.X != 0 || t.Y != 0 {
+			*t = components.Target{}
+			v := components.Velocity.Get(entry)
+			v.X, v.Y = 0, 0
+		}
+		if weapon.Reload == 0 {
+			health := components.HealthRes.Get(targetEntry)
+			health.Current -= weapon.Damage
+			weapon.Reload = weapon.Cooldown
+			weapon.Flash = 6
+		}
+	})
+}
+
+// UpdateTurrets lets defensive structures fire at the closest enemy within range. Turrets keep firing at the
+// same target while it stays in range, and hold their fire while their owner is low on power.
+func UpdateTurrets(ecs *ecs.ECS) {
+	qTurrets.Each(ecs.World, func(entry *donburi.Entry) {
+		weapon := components.WeaponRes.Get(entry)
+		if weapon.Reload > 0 {
+			weapon.Reload--
+		}
+		if weapon.Flash > 0 {
+			weapon.Flash--
+		}
+
+		attack := components.AttackRes.Get(entry)
+		if lowPower(ecs.World, components.OwnerRes.Get(entry).Faction) {
+			attack.Target = 0
+			return
+		}
+
+		// Let go of targets that were destroyed or left the turret's range.
+		p := components.Position.Get(entry)
+		if attack.Target != 0 {
+			if !ecs.World.Valid(attack.Target) {
+				attack.Target = 0
+			} else if tp := components.Position.Get(ecs.World.Entry(attack.Target)); math.Hypot(tp.X-p.X, tp.Y-p.Y) > weapon.Range {
+				attack.Target = 0
+			}
+		}
+		if attack.Target == 0 {
+			enemy := findNearestEnemy(ecs.World, entry, weapon.Range)
+			if enemy == nil {
+				return
+			}
+			attack.Target = enemy.Entity()
+		}
+
+		if weapon.Reload == 0 {
+			components.HealthRes.Get(ecs.World.Entry(attack.Target)).Current -= weapon.Damage
+			weapon.Reload = weapon.Cooldown
+			weapon.Flash = 6
+		}
+	})
+}
+
+// findNearestEnemy returns the closest unit or building of another faction within maxDist of an entity, or nil.
+func findNearestEnemy(w donburi.World, entry *donburi.Entry, maxDist float64) *donburi.Entry {
+	owner := components.OwnerRes.Get(entry).Faction
+	p := components.Position.Get(entry)
+	return nearestEntity(w, p.X, p.Y, maxDist, func(other *donburi.Entry) bool {
+		return isAttackable(other) && other.HasComponent(components.OwnerRes) && !IsOwnedBy(other, owner)
+	})
+}
+
+// RemoveDead removes every entity whose health has dropped to zero.
+func RemoveDead(ecs *ecs.ECS) {
+	// Collect the dead first so the world isn't modified while iterating over it.
+	var dead []donburi.Entity
+	qMortal.Each(ecs.World, func(entry *donburi.Entry) {
+		if components.HealthRes.Get(entry).Current <= 0 {
+			dead = append(dead, entry.Entity())
+		}
+	})
+	for _, e := range dead {
+		concealVision(ecs.World, ecs.World.Entry(e))
+		ecs.World.Remove(e)
+	}
+}
+
+// DrawCombat renders a tracer from every unit and turret that has just fired to its target.
+func DrawCombat(ecs *ecs.ECS, screen *ebiten.Image) {
+	cameraEntry, _ := camera.CameraQuery.First(ecs.World)
+	cam := camera.CameraRes.Get(cameraEntry)
+
+	qArmed.Each(ecs.World, func(entry *donburi.Entry) {
+		weapon := components.WeaponRes.Get(entry)
+		attack := components.AttackRes.Get(entry)
+		if weapon.Flash == 0 || attack.Target == 0 || !ecs.World.Valid(attack.Target) {
+			return
+		}
+		// Shots fired by or at something the player can't see would give it away.
+		target := ecs.World.Entry(attack.Target)
+		if !inSight(ecs.World, entry) || !inSight(ecs.World, target) {
+			return
+		}
+
+		fromX, fromY := cam.WorldToScreen(entityCenter(entry))
+		toX, toY := cam.WorldToScreen(entityCenter(target))
+		vector.StrokeLine(screen, float32(fromX), float32(fromY), float32(toX), float32(toY), 2, color.RGBA{R: 255, G: 220, B: 64, A: 255}, false)
+	})
+}
+
+// entityCenter returns the world position of the center of an entity.
+func entityCenter(entry *donburi.Entry) (float64, float64) {
+	p := components.Position.Get(entry)
+	width, height := entitySize(entry)
+	return p.X + width/2, p.Y + height/2
+}
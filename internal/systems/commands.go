@@ -0,0 +1,135 @@
+package systems
+
+import (
+	"github.com/gfeyer/ebit/internal/command"
+	"github.com/gfeyer/ebit/internal/components"
+	"github.com/gfeyer/ebit/internal/factory"
+	"github.com/yohamta/donburi"
+	"github.com/yohamta/donburi/ecs"
+)
+
+// ProcessCommands applies the commands of the current tick: first the incoming ones, then those issued locally
+// unless the buffer holds them back. Every command is validated against the current state, so commands referring
+// to destroyed entities or to entities of another faction are dropped. The applied commands are kept in the
+// buffer until the next tick.
+func ProcessCommands(ecs *ecs.ECS) {
+	buffer := command.GetBuffer(ecs.World)
+	pending := append([]command.Command(nil), buffer.Incoming...)
+	if buffer.HoldLocal {
+		buffer.Outgoing = append(buffer.Outgoing[:0], buffer.Local...)
+	} else {
+		pending = append(pending, buffer.Local...)
+	}
+	buffer.Applied = buffer.Applied[:0]
+	for _, cmd := range pending {
+		if applyCommand(ecs.World, cmd) {
+			buffer.Applied = append(buffer.Applied, cmd)
+		}
+	}
+	buffer.Incoming = nil
+	buffer.Local = nil
+	buffer.Tick++
+}
+
+// applyCommand carries out a single command. It returns false if the command was invalid and had no effect.
+func applyCommand(w donburi.World, cmd command.Command) bool {
+	switch cmd.Kind {
+	case command.Move:
+		return forEachUnit(w, cmd, func(entry *donburi.Entry) bool {
+			orderMove(entry, cmd.X, cmd.Y)
+			return true
+		})
+	case command.Harvest:
+		spice := validEntry(w, cmd.Target)
+		if spice == nil || !spice.HasComponent(components.SpiceRes) {
+			return false
+		}
+		return forEachUnit(w, cmd, func(entry *donburi.Entry) bool {
+			if !entry.HasComponent(components.HarvesterRes) {
+				return false
+			}
+			orderHarvest(entry, spice)
+			return true
+		})
+	case command.Attack:
+		target := validEntry(w, cmd.Target)
+		if target == nil || !target.HasComponent(components.HealthRes) || !target.HasComponent(components.OwnerRes) || IsOwnedBy(target, cmd.Faction) {
+			return false
+		}
+		return forEachUnit(w, cmd, func(entry *donburi.Entry) bool {
+			if !entry.HasComponent(components.AttackRes) {
+				return false
+			}
+			orderAttack(entry, target)
+			return true
+		})
+	case command.Stop:
+		return forEachUnit(w, cmd, func(entry *donburi.Entry) bool {
+			orderStop(entry)
+			return true
+		})
+	case command.TrainUnit:
+		building := ownedEntry(w, cmd.Target, cmd.Faction)
+		info := findUnitInfo(w, cmd.UnitType)
+		if building == nil || info == nil || !building.HasComponent(components.ProductionRes) {
+			return false
+		}
+		if btype, ok := factory.BuildingTypeOf(building); !ok || btype != info.RequiredBuilding {
+			return false
+		}
+		if unitLocked(w, cmd.Faction, info.Type) {
+			return false
+		}
+		return enqueueUnit(w, building, info)
+	case command.CancelUnit:
+		building := ownedEntry(w, cmd.Target, cmd.Faction)
+		if building == nil || !building.HasComponent(components.ProductionRes) {
+			return false
+		}
+		return cancelUnit(w, building, cmd.UnitType)
+	case command.StartConstruction:
+		info := findBuildInfo(w, cmd.BuildingType)
+		if info == nil || buildingLocked(w, cmd.Faction, info.Type) {
+			return false
+		}
+		return startConstruction(w, cmd.Faction, info)
+	case command.CancelConstruction:
+		return cancelConstruction(w, cmd.Faction)
+	case command.PlaceBuilding:
+		return placeBuilding(w, cmd.Faction, cmd.X, cmd.Y)
+	}
+	return false
+}
+
+// forEachUnit runs an order for every unit of a command that still exists and belongs to the command's faction.
+// It returns true if the order applied to at least one unit.
+func forEachUnit(w donburi.World, cmd command.Command, order func(entry *donburi.Entry) bool) bool {
+	applied := false
+	for _, e := range cmd.Units {
+		entry := ownedEntry(w, e, cmd.Faction)
+		if entry == nil || !entry.HasComponent(components.UnitRes) {
+			continue
+		}
+		if order(entry) {
+			applied = true
+		}
+	}
+	return applied
+}
+
+// validEntry returns the entry of an entity that still exists, or nil.
+func validEntry(w donburi.World, e donburi.Entity) *donburi.Entry {
+	if e == donburi.Null || !w.Valid(e) {
+		return nil
+	}
+	return w.Entry(e)
+}
+
+// ownedEntry returns the entry of an entity that still exists and belongs to a faction, or nil.
+func ownedEntry(w donburi.World, e donburi.Entity, faction components.Faction) *donburi.Entry {
+	entry := validEntry(w, e)
+	if entry == nil || !IsOwnedBy(entry, faction) {
+		return nil
+	}
+	return entry
+}
@@ -1,92 +1,157 @@
 package systems
 
 import (
-	"image/color"
+	"math"
 
 	"github.com/gfeyer/ebit/internal/camera"
 	"github.com/gfeyer/ebit/internal/components"
 	"github.com/gfeyer/ebit/internal/fog"
-	"github.com/gfeyer/ebit/internal/settings"
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/vector"
 	"github.com/yohamta/donburi"
 	"github.com/yohamta/donburi/ecs"
 	"github.com/yohamta/donburi/filter"
 )
 
 var (
-	// qPlayerUnits retrieves all player-controlled units and buildings that provide vision.
-	qPlayerUnits = donburi.NewQuery(filter.And(
-		filter.Or(filter.Contains(components.UnitRes), filter.Contains(components.RefineryRes), filter.Contains(components.BarracksRes)),
-		filter.Contains(components.Position),
-	))
+	// qViewers retrieves all units and buildings that can provide vision.
+	qViewers = donburi.NewQuery(filter.Contains(components.Position, components.VisionRes, components.OwnerRes))
+
+	// fogImage is the image of the fog of war drawn on the screen and on the minimap; see fogTexture.
+	fogImage *ebiten.Image
+	// fogPixels is the pixel buffer fogImage is rendered from.
+	fogPixels []byte
+	// fogImageGrid and fogImageVersion identify the state of the fog fogImage was rendered from.
+	fogImageGrid    [][]fog.VisibilityState
+	fogImageVersion uint64
 )
 
-// UpdateFog manages the fog of war logic. It first shrouds all previously visible areas
-// and then reveals the areas around the player's current units and buildings.
+// UpdateFog reveals the fog around the local player's units and buildings. The fog counts how many of them see
+// each tile, so only the tiles around a viewer that moved to another tile, appeared or went away are updated;
+// tiles no viewer sees anymore become shrouded.
 func UpdateFog(ecs *ecs.ECS) {
 	fogRes := fog.GetFog(ecs.World)
 
-	// 1. Set all currently visible tiles to shrouded. This creates the effect of areas becoming
-	//    unexplored again when units move away.
-	for y := 0; y < fogRes.Height; y++ {
-		for x := 0; x < fogRes.Width; x++ {
-			if fogRes.Grid[y][x] == fog.Visible {
-				fogRes.Grid[y][x] = fog.Shroud
-			}
-		}
+	// A fog that was just loaded doesn't know its viewers, so every viewer reveals its surroundings again.
+	if !fogRes.Tracking() {
+		fogRes.Reset()
+		qViewers.Each(ecs.World, func(entry *donburi.Entry) {
+			components.VisionRes.Get(entry).Revealed = false
+		})
 	}
 
-	// 2. Reveal the fog around each of the player's units and buildings.
-	qPlayerUnits.Each(ecs.World, func(entry *donburi.Entry) {
+	local := LocalPlayer(ecs.World)
+	qViewers.Each(ecs.World, func(entry *donburi.Entry) {
+		vision := components.VisionRes.Get(entry)
 		p := components.Position.Get(entry)
-		visionRadius := 16 // in tiles
-
 		tileX := int(p.X) / fogRes.TileSize
 		tileY := int(p.Y) / fogRes.TileSize
+		sees := local != nil && IsOwnedBy(entry, local.Faction)
 
-		for y := tileY - visionRadius; y <= tileY+visionRadius; y++ {
-			for x := tileX - visionRadius; x <= tileX+visionRadius; x++ {
-				if x >= 0 && x < fogRes.Width && y >= 0 && y < fogRes.Height {
-					dx := x - tileX
-					dy := y - tileY
-					if dx*dx+dy*dy <= visionRadius*visionRadius {
-						fogRes.Grid[y][x] = fog.Visible
-					}
-				}
-			}
+		if vision.Revealed && (!sees || tileX != vision.TileX || tileY != vision.TileY) {
+			fogRes.Conceal(vision.TileX, vision.TileY, vision.Radius)
+			vision.Revealed = false
+		}
+		if sees && !vision.Revealed {
+			fogRes.Reveal(tileX, tileY, vision.Radius)
+			*vision = components.Vision{Radius: vision.Radius, Revealed: true, TileX: tileX, TileY: tileY}
 		}
 	})
 }
 
-// DrawFog renders the fog of war onto the screen. It draws a black rectangle for hidden areas
-// and a semi-transparent one for shrouded areas.
+// concealVision stops counting an entity that is about to be removed as a viewer of the fog.
+func concealVision(w donburi.World, entry *donburi.Entry) {
+	if !entry.HasComponent(components.VisionRes) {
+		return
+	}
+	if vision := components.VisionRes.Get(entry); vision.Revealed {
+		fog.GetFog(w).Conceal(vision.TileX, vision.TileY, vision.Radius)
+		vision.Revealed = false
+	}
+}
+
+// inSight reports whether the local player can see an entity right now: it is their own, or its center lies on a
+// visible tile. Callers use it to hide enemy units under the shroud; terrain, spice and buildings stay drawn there.
+func inSight(w donburi.World, entry *donburi.Entry) bool {
+	local := LocalPlayer(w)
+	if local == nil || IsOwnedBy(entry, local.Faction) {
+		return true
+	}
+	fogRes := fog.GetFog(w)
+	x, y := entityCenter(entry)
+	tileX, tileY := int(x)/fogRes.TileSize, int(y)/fogRes.TileSize
+	if x < 0 || y < 0 || tileX >= fogRes.Width || tileY >= fogRes.Height {
+		return false
+	}
+	return fogRes.State(tileX, tileY) == fog.Visible
+}
+
+// explored reports whether the local player has seen any part of an entity: it is their own, or a tile it covers
+// isn't hidden. Buildings stay drawn under the shroud, so their labels and health bars are shown wherever they are.
+func explored(w donburi.World, entry *donburi.Entry) bool {
+	local := LocalPlayer(w)
+	if local == nil || IsOwnedBy(entry, local.Faction) {
+		return true
+	}
+	fogRes := fog.GetFog(w)
+	p := components.Position.Get(entry)
+	width, height := entitySize(entry)
+	minX, minY := max(int(p.X)/fogRes.TileSize, 0), max(int(p.Y)/fogRes.TileSize, 0)
+	maxX := min(int(math.Ceil((p.X+width)/float64(fogRes.TileSize))), fogRes.Width)
+	maxY := min(int(math.Ceil((p.Y+height)/float64(fogRes.TileSize))), fogRes.Height)
+	for y := minY; y < maxY; y++ {
+		for x := minX; x < maxX; x++ {
+			if fogRes.State(x, y) != fog.Hidden {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DrawFog renders the fog of war onto the screen: black over hidden areas and semi-transparent over shrouded ones.
+// The fog texture is scaled up with linear filtering, so the edges between the states are soft.
 func DrawFog(ecs *ecs.ECS, screen *ebiten.Image) {
 	cameraEntry, _ := camera.CameraQuery.First(ecs.World)
 	cam := camera.CameraRes.Get(cameraEntry)
 	fogRes := fog.GetFog(ecs.World)
-	s := settings.GetSettings(ecs.World)
-
-	for y := 0; y < fogRes.Height; y++ {
-		for x := 0; x < fogRes.Width; x++ {
-			worldX := float32(x * fogRes.TileSize)
-			worldY := float32(y * fogRes.TileSize)
 
-			screenX := worldX - float32(cam.X)
-			screenY := worldY - float32(cam.Y)
+	// The texture's border pixel lies one tile outside the map.
+	op := &ebiten.DrawImageOptions{Filter: ebiten.FilterLinear}
+	op.GeoM.Scale(float64(fogRes.TileSize), float64(fogRes.TileSize))
+	op.GeoM.Translate(-float64(fogRes.TileSize), -float64(fogRes.TileSize))
+	op.GeoM.Concat(cam.GeoM())
+	screen.DrawImage(fogTexture(fogRes), op)
+}
 
-			// Culling: Don't draw fog tiles that are outside the camera's view.
-			if screenX+float32(fogRes.TileSize) < 0 || screenX > float32(s.ScreenWidth) || screenY+float32(fogRes.TileSize) < 0 || screenY > float32(s.ScreenHeight) {
-				continue
-			}
+// fogTexture returns an image of the fog of war with one pixel per fog tile, rendering it again only when the fog
+// has changed. The image has a border of one pixel repeating the tiles on the map's edge, so it doesn't fade out
+// at the edge of the map when it is scaled up.
+func fogTexture(fogRes *fog.Fog) *ebiten.Image {
+	width, height := fogRes.Width+2, fogRes.Height+2
+	if fogImage != nil && len(fogImageGrid) > 0 && &fogImageGrid[0] == &fogRes.Grid[0] && fogImageVersion == fogRes.Version() {
+		return fogImage
+	}
+	if fogImage == nil || fogImage.Bounds().Dx() != width || fogImage.Bounds().Dy() != height {
+		fogImage = ebiten.NewImage(width, height)
+		fogPixels = make([]byte, width*height*4)
+	}
+	fogImageGrid, fogImageVersion = fogRes.Grid, fogRes.Version()
 
-			// Render the fog tile based on its state (Hidden, Shroud, or Visible).
-			switch fogRes.Grid[y][x] {
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			tx := min(max(x-1, 0), fogRes.Width-1)
+			ty := min(max(y-1, 0), fogRes.Height-1)
+			var alpha byte
+			switch fogRes.State(tx, ty) {
 			case fog.Hidden:
-				vector.DrawFilledRect(screen, screenX, screenY, float32(fogRes.TileSize), float32(fogRes.TileSize), color.Black, false)
+				alpha = 255 // Black
 			case fog.Shroud:
-				vector.DrawFilledRect(screen, screenX, screenY, float32(fogRes.TileSize), float32(fogRes.TileSize), color.RGBA{0, 0, 0, 180}, false)
+				alpha = 180 // Semi-transparent black
 			}
+			// The pixels are premultiplied, so black only needs its alpha.
+			fogPixels[(y*width+x)*4+3] = alpha
 		}
 	}
+	fogImage.WritePixels(fogPixels)
+	return fogImage
 }
@@ -11,17 +11,15 @@ import (
 
 var (
 	// qHarvesters retrieves all harvester units.
-	qHarvesters = donburi.NewQuery(filter.Contains(components.UnitRes, components.HarvesterRes, components.Position, components.TargetRes))
+	qHarvesters = donburi.NewQuery(filter.Contains(components.UnitRes, components.HarvesterRes, components.Position, components.TargetRes, components.OwnerRes))
 	// qSpice retrieves all spice fields on the map.
-	qSpice = donburi.NewQuery(filter.Contains(components.Position, components.Sprite, components.SpiceRes, components.SpiceAmountRes))
-	// qRefinery retrieves all player refineries.
-	qRefinery = donburi.NewQuery(filter.Contains(components.RefineryRes, components.Position))
+	qSpice = donburi.NewQuery(filter.Contains(components.Position, components.SizeRes, components.SpiceRes, components.SpiceAmountRes))
 )
 
 // handleIdle manages the behavior of a harvester when it is in the Idle state.
 // If it has a target spice field, it will start moving towards it.
 // If it's carrying spice, it will move to a refinery.
-func handleIdle(ecs *ecs.ECS, harvester *components.HarvesterData, p *components.Pos, t *components.Target) {
+func handleIdle(ecs *ecs.ECS, entry *donburi.Entry, harvester *components.HarvesterData, p *components.Pos, t *components.Target) {
 	// Harvester is idle, waiting for a command.
 	// If it has a target spice, it means it has completed a loop and should go back.
 	if harvester.TargetSpice != 0 {
@@ -43,7 +41,7 @@ func handleIdle(ecs *ecs.ECS, harvester *components.HarvesterData, p *components
 	if harvester.CarriedAmount > 0 {
 		harvester.State = components.StateMovingToRefinery
 		// Find the closest refinery and set it as the target
-		if closestRefinery := findClosestRefinery(ecs, p); closestRefinery != nil {
+		if closestRefinery := findClosestRefinery(ecs, entry, p); closestRefinery != nil {
 			harvester.TargetRefinery = closestRefinery.Entity()
 			refineryPos := components.Position.Get(closestRefinery)
 			t.X, t.Y = refineryPos.X, refineryPos.Y
@@ -80,24 +78,12 @@ func handleMovingToSpice(ecs *ecs.ECS, entry *donburi.Entry, harvester *componen
 	}
 }
 
-// findClosestRefinery finds the refinery nearest to a given position.
-func findClosestRefinery(ecs *ecs.ECS, p *components.Pos) *donburi.Entry {
-	var closestRefinery *donburi.Entry
-	minDist := math.MaxFloat64
-
-	qRefinery.Each(ecs.World, func(refineryEntry *donburi.Entry) {
-		refineryPos := components.Position.Get(refineryEntry)
-		dx := refineryPos.X - p.X
-		dy := refineryPos.Y - p.Y
-		dist := math.Sqrt(dx*dx + dy*dy)
-
-		if dist < minDist {
-			minDist = dist
-			closestRefinery = refineryEntry
-		}
+// findClosestRefinery finds the refinery nearest to a given position that belongs to the harvester's owner.
+func findClosestRefinery(ecs *ecs.ECS, harvesterEntry *donburi.Entry, p *components.Pos) *donburi.Entry {
+	owner := components.OwnerRes.Get(harvesterEntry).Faction
+	return nearestEntity(ecs.World, p.X, p.Y, math.MaxFloat64, func(entry *donburi.Entry) bool {
+		return entry.HasComponent(components.RefineryRes) && IsOwnedBy(entry, owner)
 	})
-
-	return closestRefinery
 }
 
 // handleHarvesting manages the process of a harvester gathering spice from a field.
@@ -108,7 +94,7 @@ func handleHarvesting(ecs *ecs.ECS, entry *donburi.Entry, harvester *components.
 		harvester.State = components.StateMovingToRefinery
 
 		// Find the closest refinery
-		if closestRefinery := findClosestRefinery(ecs, p); closestRefinery != nil {
+		if closestRefinery := findClosestRefinery(ecs, entry, p); closestRefinery != nil {
 			harvester.TargetRefinery = closestRefinery.Entity()
 			refineryPos := components.Position.Get(closestRefinery)
 			t.X, t.Y = refineryPos.X, refineryPos.Y
@@ -144,7 +130,7 @@ func handleHarvesting(ecs *ecs.ECS, entry *donburi.Entry, harvester *components.
 
 		if harvester.CarriedAmount > 0 {
 			harvester.State = components.StateMovingToRefinery
-			if closestRefinery := findClosestRefinery(ecs, p); closestRefinery != nil {
+			if closestRefinery := findClosestRefinery(ecs, entry, p); closestRefinery != nil {
 				harvester.TargetRefinery = closestRefinery.Entity()
 				refineryPos := components.Position.Get(closestRefinery)
 				t.X, t.Y = refineryPos.X, refineryPos.Y
@@ -164,10 +150,10 @@ func handleHarvesting(ecs *ecs.ECS, entry *donburi.Entry, harvester *components.
 
 // handleMovingToRefinery manages the harvester's movement towards a refinery.
 // It transitions the harvester to the Unloading state upon arrival.
-func handleMovingToRefinery(ecs *ecs.ECS, harvester *components.HarvesterData, p *components.Pos, t *components.Target) {
+func handleMovingToRefinery(ecs *ecs.ECS, entry *donburi.Entry, harvester *components.HarvesterData, p *components.Pos, t *components.Target) {
 
 	if harvester.TargetRefinery == 0 || !ecs.World.Valid(harvester.TargetRefinery) || t.X == 0 && t.Y == 0 {
-		if closestRefinery := findClosestRefinery(ecs, p); closestRefinery != nil {
+		if closestRefinery := findClosestRefinery(ecs, entry, p); closestRefinery != nil {
 			harvester.TargetRefinery = closestRefinery.Entity()
 			refineryPos := components.Position.Get(closestRefinery)
 			t.X, t.Y = refineryPos.X, refineryPos.Y
@@ -188,11 +174,11 @@ func handleMovingToRefinery(ecs *ecs.ECS, harvester *components.HarvesterData, p
 }
 
 // handleUnloading manages the process of a harvester unloading its spice at a refinery.
-// It adds the spice to the player's resources and sets the harvester back to an Idle state.
-func handleUnloading(ecs *ecs.ECS, harvester *components.HarvesterData) {
-	playerEntry, _ := QPlayer.First(ecs.World)
-	player := components.PlayerRes.Get(playerEntry)
-	player.Money += harvester.CarriedAmount
+// It adds the spice to the owning player's resources and sets the harvester back to an Idle state.
+func handleUnloading(ecs *ecs.ECS, entry *donburi.Entry, harvester *components.HarvesterData) {
+	if player := GetPlayer(ecs.World, components.OwnerRes.Get(entry).Faction); player != nil {
+		player.Money += harvester.CarriedAmount
+	}
 	harvester.CarriedAmount = 0
 	harvester.State = components.StateIdle
 }
@@ -212,15 +198,15 @@ func UpdateHarvester(ecs *ecs.ECS) {
 
 		switch harvester.State {
 		case components.StateIdle:
-			handleIdle(ecs, harvester, p, t)
+			handleIdle(ecs, entry, harvester, p, t)
 		case components.StateMovingToSpice:
 			handleMovingToSpice(ecs, entry, harvester, p, t)
 		case components.StateHarvesting:
 			handleHarvesting(ecs, entry, harvester, p, t)
 		case components.StateMovingToRefinery:
-			handleMovingToRefinery(ecs, harvester, p, t)
+			handleMovingToRefinery(ecs, entry, harvester, p, t)
 		case components.StateUnloading:
-			handleUnloading(ecs, harvester)
+			handleUnloading(ecs, entry, harvester)
 		}
 	})
 }
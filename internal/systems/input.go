@@ -4,9 +4,10 @@ import (
 	"image"
 
 	"github.com/gfeyer/ebit/internal/camera"
+	"github.com/gfeyer/ebit/internal/command"
 	"github.com/gfeyer/ebit/internal/components"
+	"github.com/gfeyer/ebit/internal/input"
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/yohamta/donburi"
 	"github.com/yohamta/donburi/ecs"
 )
@@ -21,21 +22,28 @@ func abs(x int) int {
 // UpdateInput handles user input for selecting and commanding units.
 // It processes mouse clicks for selection, drag-selection, and issuing orders.
 func UpdateInput(ecs *ecs.ECS) {
+	in := input.GetInput(ecs.World)
 	dragEntry, _ := QDrag.First(ecs.World)
 	drag := components.DragRes.Get(dragEntry)
 
 	// When the left mouse button is pressed, start a drag operation.
-	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+	if in.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
 		drag.IsDragging = true
-		drag.StartX, drag.StartY = ebiten.CursorPosition()
+		drag.StartX, drag.StartY = in.CursorPosition()
 	}
 
 	if drag.IsDragging {
-		drag.EndX, drag.EndY = ebiten.CursorPosition()
+		drag.EndX, drag.EndY = in.CursorPosition()
+	}
+
+	// Only the local player's units can be selected and commanded.
+	local := LocalPlayer(ecs.World)
+	if local == nil {
+		return
 	}
 
 	// When the left mouse button is released, finalize the selection.
-	if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) {
+	if in.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) {
 		drag.IsDragging = false
 
 		cameraEntry, _ := camera.CameraQuery.First(ecs.World)
@@ -48,25 +56,27 @@ func UpdateInput(ecs *ecs.ECS) {
 				components.SelectableRes.Get(entry).Selected = false
 			})
 
-			// Create a selection rectangle and select all units within it.
+			// Create a selection rectangle and select all of the player's units within it.
 			rect := image.Rect(drag.StartX, drag.StartY, drag.EndX, drag.EndY).Canon()
-			SelectableUnitQuery.Each(ecs.World, func(entry *donburi.Entry) {
+			x0, y0 := cam.ScreenToWorld(float64(rect.Min.X), float64(rect.Min.Y))
+			x1, y1 := cam.ScreenToWorld(float64(rect.Max.X), float64(rect.Max.Y))
+			entitiesNear(ecs.World, x0, y0, x1, y1, func(entry *donburi.Entry) {
+				if !entry.HasComponent(components.UnitRes) || !entry.HasComponent(components.SelectableRes) || !IsOwnedBy(entry, local.Faction) {
+					return
+				}
 				p := components.Position.Get(entry)
-				screenX, screenY := int(p.X-cam.X), int(p.Y-cam.Y)
-				if image.Pt(screenX, screenY).In(rect) {
+				screenX, screenY := cam.WorldToScreen(p.X, p.Y)
+				if image.Pt(int(screenX), int(screenY)).In(rect) {
 					components.SelectableRes.Get(entry).Selected = true
 				}
 			})
 		} else { // If the mouse didn't move much, treat it as a single-click selection.
-			mx, my := ebiten.CursorPosition()
-			wx, wy := float64(mx)+cam.X, float64(my)+cam.Y
+			mx, my := in.CursorPosition()
+			wx, wy := cam.ScreenToWorld(float64(mx), float64(my))
 
 			var clickedUnit *donburi.Entry
-			QSelectable.Each(ecs.World, func(entry *donburi.Entry) {
-				p := components.Position.Get(entry)
-				s := components.Sprite.Get(entry)
-				bounds := (*s).Bounds()
-				if wx >= p.X && wx < p.X+float64(bounds.Dx()) && wy >= p.Y && wy < p.Y+float64(bounds.Dy()) {
+			entitiesAt(ecs.World, wx, wy, func(entry *donburi.Entry) {
+				if entry.HasComponent(components.SelectableRes) && IsOwnedBy(entry, local.Faction) {
 					clickedUnit = entry
 				}
 			})
@@ -84,44 +94,64 @@ func UpdateInput(ecs *ecs.ECS) {
 	}
 
 	// Handle right-click commands for selected units.
-	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) {
+	if in.IsMouseButtonJustPressed(ebiten.MouseButtonRight) {
 		cameraEntry, _ := camera.CameraQuery.First(ecs.World)
 		cam := camera.CameraRes.Get(cameraEntry)
-		mx, my := ebiten.CursorPosition()
-		wx, wy := float64(mx)+cam.X, float64(my)+cam.Y
-
-		// Check if the right-click targeted a spice field.
-		var targetSpice *donburi.Entry
-		QSpice.Each(ecs.World, func(spiceEntry *donburi.Entry) {
-			spicePos := components.Position.Get(spiceEntry)
-			spiceSprite := components.Sprite.Get(spiceEntry)
-			bounds := (*spiceSprite).Bounds()
-			if wx >= spicePos.X && wx < spicePos.X+float64(bounds.Dx()) && wy >= spicePos.Y && wy < spicePos.Y+float64(bounds.Dy()) {
-				targetSpice = spiceEntry
+		mx, my := in.CursorPosition()
+		wx, wy := cam.ScreenToWorld(float64(mx), float64(my))
+
+		// Check if the right-click targeted a spice field or an enemy unit or building.
+		var targetSpice, targetEnemy *donburi.Entry
+		entitiesAt(ecs.World, wx, wy, func(entry *donburi.Entry) {
+			if entry.HasComponent(components.SpiceRes) {
+				targetSpice = entry
+			}
+			if isAttackable(entry) && entry.HasComponent(components.OwnerRes) && !IsOwnedBy(entry, local.Faction) {
+				targetEnemy = entry
 			}
 		})
 
+		var attackers, harvesters, movers []donburi.Entity
 		QSelectable.Each(ecs.World, func(entry *donburi.Entry) {
-			if components.SelectableRes.Get(entry).Selected && entry.HasComponent(components.UnitRes) {
+			if components.SelectableRes.Get(entry).Selected && entry.HasComponent(components.UnitRes) && IsOwnedBy(entry, local.Faction) {
 				unit := components.UnitRes.Get(entry)
-				// If a selected unit is a harvester and the target is a spice field, command it to harvest.
-				// If it's a harvester and a spice field was clicked, set it as the target
-				if unit.Type == components.Harvester && targetSpice != nil {
-					harvester := components.HarvesterRes.Get(entry)
-					harvester.State = components.StateMovingToSpice
-					harvester.TargetSpice = targetSpice.Entity()
-					spicePos := components.Position.Get(targetSpice)
-					*components.TargetRes.Get(entry) = components.Target{X: spicePos.X, Y: spicePos.Y}
+				if targetEnemy != nil && entry.HasComponent(components.WeaponRes) {
+					// If a selected unit is armed and an enemy was clicked, command it to attack.
+					attackers = append(attackers, entry.Entity())
+				} else if unit.Type == components.Harvester && targetSpice != nil {
+					// If a selected unit is a harvester and the target is a spice field, command it to harvest.
+					harvesters = append(harvesters, entry.Entity())
 				} else { // Otherwise, issue a standard move command to the target location.
-					*components.TargetRes.Get(entry) = components.Target{X: wx, Y: wy}
-					// If it was a harvester, clear its spice target
-					if unit.Type == components.Harvester {
-						harvester := components.HarvesterRes.Get(entry)
-						harvester.State = components.StateIdle
-						harvester.TargetSpice = 0
-					}
+					movers = append(movers, entry.Entity())
 				}
 			}
 		})
+		if len(attackers) > 0 {
+			command.Issue(ecs.World, command.Command{Kind: command.Attack, Faction: local.Faction, Units: attackers, Target: targetEnemy.Entity()})
+		}
+		if len(harvesters) > 0 {
+			command.Issue(ecs.World, command.Command{Kind: command.Harvest, Faction: local.Faction, Units: harvesters, Target: targetSpice.Entity()})
+		}
+		if len(movers) > 0 {
+			command.Issue(ecs.World, command.Command{Kind: command.Move, Faction: local.Faction, Units: movers, X: wx, Y: wy})
+		}
+	}
+
+	// The S key stops all selected units.
+	if in.IsKeyJustPressed(ebiten.KeyS) {
+		if units := selectedUnits(ecs.World, local.Faction); len(units) > 0 {
+			command.Issue(ecs.World, command.Command{Kind: command.Stop, Faction: local.Faction, Units: units})
+		}
 	}
 }
+
+// selectedUnits returns the selected units of a faction.
+func selectedUnits(w donburi.World, faction components.Faction) []donburi.Entity {
+	var units []donburi.Entity
+	SelectableUnitQuery.Each(w, func(entry *donburi.Entry) {
+		if components.SelectableRes.Get(entry).Selected && IsOwnedBy(entry, faction) {
+			units = append(units, entry.Entity())
+		}
+	})
+	return units
+}
@@ -1,39 +1,53 @@
 package systems
 
 import (
+	"image"
 	"image/color"
 
 	"github.com/gfeyer/ebit/internal/camera"
+	"github.com/gfeyer/ebit/internal/command"
 	"github.com/gfeyer/ebit/internal/components"
+	"github.com/gfeyer/ebit/internal/factory"
 	"github.com/gfeyer/ebit/internal/fog"
+	"github.com/gfeyer/ebit/internal/input"
 	"github.com/gfeyer/ebit/internal/settings"
+	"github.com/gfeyer/ebit/internal/terrain"
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
 	"github.com/hajimehoshi/ebiten/v2/vector"
 	"github.com/yohamta/donburi"
 	"github.com/yohamta/donburi/ecs"
 	"github.com/yohamta/donburi/filter"
+	"golang.org/x/image/font/basicfont"
 )
 
 var (
 	// MinimapQuery retrieves the minimap entity.
 	MinimapQuery = donburi.NewQuery(filter.Contains(components.MinimapRes))
 
-	// minimapFogImage is a pre-rendered image of the fog of war for the minimap.
-	minimapFogImage *ebiten.Image
+	// minimapTerrainImage is a pre-rendered image of the terrain for the minimap, one pixel per tile.
+	minimapTerrainImage *ebiten.Image
+	// minimapTerrainGrid is the terrain grid minimapTerrainImage was rendered from. It changes when a game is loaded.
+	minimapTerrainGrid [][]terrain.TerrainType
 )
 
 // UpdateMinimap handles user input on the minimap, such as moving the camera or commanding units.
 func UpdateMinimap(ecs *ecs.ECS) {
+	in := input.GetInput(ecs.World)
 	minimapEntry, ok := MinimapQuery.First(ecs.World)
 	if !ok {
 		return
 	}
 	minimap := components.MinimapRes.Get(minimapEntry)
 
+	// An offline minimap doesn't respond to clicks.
+	if minimapOffline(ecs.World) {
+		return
+	}
+
 	// A left-click on the minimap moves the camera to the corresponding world position.
-	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
-		mx, my := ebiten.CursorPosition()
+	if in.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		mx, my := in.CursorPosition()
 		if mx >= minimap.X && mx < minimap.X+minimap.Width && my >= minimap.Y && my < minimap.Y+minimap.Height {
 			settings := settings.GetSettings(ecs.World)
 			cameraEntry, _ := camera.CameraQuery.First(ecs.World)
@@ -42,14 +56,13 @@ func UpdateMinimap(ecs *ecs.ECS) {
 			scaleX := float64(minimap.Width) / float64(settings.MapWidth)
 			scaleY := float64(minimap.Height) / float64(settings.MapHeight)
 
-			cam.X = (float64(mx-minimap.X) / scaleX) - float64(settings.ScreenWidth)/2
-			cam.Y = (float64(my-minimap.Y) / scaleY) - float64(settings.ScreenHeight)/2
+			cam.CenterOn(settings, float64(mx-minimap.X)/scaleX, float64(my-minimap.Y)/scaleY)
 		}
 	}
 
 	// A right-click on the minimap commands all selected units to move to the corresponding world position.
-	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) {
-		mx, my := ebiten.CursorPosition()
+	if in.IsMouseButtonJustPressed(ebiten.MouseButtonRight) {
+		mx, my := in.CursorPosition()
 		if mx >= minimap.X && mx < minimap.X+minimap.Width && my >= minimap.Y && my < minimap.Y+minimap.Height {
 			settings := settings.GetSettings(ecs.World)
 			scaleX := float64(minimap.Width) / float64(settings.MapWidth)
@@ -58,11 +71,11 @@ func UpdateMinimap(ecs *ecs.ECS) {
 			wx := (float64(mx-minimap.X) / scaleX)
 			wy := (float64(my-minimap.Y) / scaleY)
 
-			QSelectable.Each(ecs.World, func(entry *donburi.Entry) {
-				if components.SelectableRes.Get(entry).Selected {
-					*components.TargetRes.Get(entry) = components.Target{X: wx, Y: wy}
+			if local := LocalPlayer(ecs.World); local != nil {
+				if units := selectedUnits(ecs.World, local.Faction); len(units) > 0 {
+					command.Issue(ecs.World, command.Command{Kind: command.Move, Faction: local.Faction, Units: units, X: wx, Y: wy})
 				}
-			})
+			}
 		}
 	}
 }
@@ -75,12 +88,39 @@ func DrawMinimap(ecs *ecs.ECS, screen *ebiten.Image) {
 	}
 	minimap := components.MinimapRes.Get(minimapEntry)
 
+	// While the local player is low on power, the minimap only shows that it is offline.
+	if minimapOffline(ecs.World) {
+		vector.DrawFilledRect(screen, float32(minimap.X), float32(minimap.Y), float32(minimap.Width), float32(minimap.Height), color.Black, false)
+		label := "OFFLINE"
+		bounds := text.BoundString(basicfont.Face7x13, label)
+		text.Draw(screen, label, basicfont.Face7x13, minimap.X+(minimap.Width-bounds.Dx())/2, minimap.Y+minimap.Height/2, color.RGBA{R: 255, G: 96, B: 96, A: 255})
+		vector.StrokeRect(screen, float32(minimap.X), float32(minimap.Y), float32(minimap.Width), float32(minimap.Height), 1, color.White, false)
+		return
+	}
+
 	settings := settings.GetSettings(ecs.World)
 	cameraEntry, _ := camera.CameraQuery.First(ecs.World)
 	cam := camera.CameraRes.Get(cameraEntry)
 
-	// Draw the minimap's sand-colored background.
-	vector.DrawFilledRect(screen, float32(minimap.X), float32(minimap.Y), float32(minimap.Width), float32(minimap.Height), color.RGBA{210, 180, 140, 255}, false)
+	// Lazily render the terrain into an image with one pixel per tile, then draw it as the minimap's background.
+	ter := terrain.GetTerrain(ecs.World)
+	if minimapTerrainImage == nil || len(minimapTerrainGrid) == 0 || &minimapTerrainGrid[0] != &ter.Grid[0] {
+		minimapTerrainImage = ebiten.NewImage(ter.Width, ter.Height)
+		minimapTerrainGrid = ter.Grid
+		terrainPixels := make([]byte, ter.Width*ter.Height*4)
+		for y := 0; y < ter.Height; y++ {
+			for x := 0; x < ter.Width; x++ {
+				idx := (y*ter.Width + x) * 4
+				c := terrainColors[ter.Grid[y][x]]
+				terrainPixels[idx], terrainPixels[idx+1], terrainPixels[idx+2], terrainPixels[idx+3] = c.R, c.G, c.B, c.A
+			}
+		}
+		minimapTerrainImage.WritePixels(terrainPixels)
+	}
+	terrainOp := &ebiten.DrawImageOptions{}
+	terrainOp.GeoM.Scale(float64(minimap.Width)/float64(ter.Width), float64(minimap.Height)/float64(ter.Height))
+	terrainOp.GeoM.Translate(float64(minimap.X), float64(minimap.Y))
+	screen.DrawImage(minimapTerrainImage, terrainOp)
 
 	// Calculate the scaling factors to convert world coordinates to minimap coordinates.
 	scaleX := float64(minimap.Width) / float64(settings.MapWidth)
@@ -88,7 +128,8 @@ func DrawMinimap(ecs *ecs.ECS, screen *ebiten.Image) {
 
 	fogRes := fog.GetFog(ecs.World)
 
-	// Draw all visible units on the minimap as green dots.
+	// Draw the player's units as green dots and enemy units currently in sight as dots in their faction's color.
+	local := LocalPlayer(ecs.World)
 	QSelectable.Each(ecs.World, func(entry *donburi.Entry) {
 		pos := components.Position.Get(entry)
 
@@ -96,10 +137,16 @@ func DrawMinimap(ecs *ecs.ECS, screen *ebiten.Image) {
 		tileY := int(pos.Y) / fogRes.TileSize
 
 		if tileX >= 0 && tileX < fogRes.Width && tileY >= 0 && tileY < fogRes.Height {
-			if fogRes.Grid[tileY][tileX] != fog.Hidden {
+			dotColor := color.RGBA{G: 255, A: 255}
+			visible := fogRes.State(tileX, tileY) != fog.Hidden
+			if local != nil && !IsOwnedBy(entry, local.Faction) {
+				dotColor = factory.FactionColor(components.OwnerRes.Get(entry).Faction)
+				visible = fogRes.State(tileX, tileY) == fog.Visible
+			}
+			if visible {
 				unitX := float32(minimap.X + int(pos.X*scaleX))
 				unitY := float32(minimap.Y + int(pos.Y*scaleY))
-				vector.DrawFilledRect(screen, unitX-1, unitY-1, 3, 3, color.RGBA{G: 255, A: 255}, false)
+				vector.DrawFilledRect(screen, unitX-1, unitY-1, 3, 3, dotColor, false)
 			}
 		}
 	})
@@ -112,7 +159,7 @@ func DrawMinimap(ecs *ecs.ECS, screen *ebiten.Image) {
 		tileY := int(pos.Y) / fogRes.TileSize
 
 		if tileX >= 0 && tileX < fogRes.Width && tileY >= 0 && tileY < fogRes.Height {
-			if fogRes.Grid[tileY][tileX] != fog.Hidden {
+			if fogRes.State(tileX, tileY) != fog.Hidden {
 				spiceX := float32(minimap.X + int(pos.X*scaleX))
 				spiceY := float32(minimap.Y + int(pos.Y*scaleY))
 				vector.DrawFilledRect(screen, spiceX, spiceY, 2, 2, color.RGBA{R: 255, G: 140, A: 255}, false)
@@ -120,40 +167,28 @@ func DrawMinimap(ecs *ecs.ECS, screen *ebiten.Image) {
 		}
 	})
 
-	// Lazily initialize the pre-rendered fog image for the minimap if it doesn't exist or its size is incorrect.
-	if minimapFogImage == nil || minimapFogImage.Bounds().Dx() != fogRes.Width || minimapFogImage.Bounds().Dy() != fogRes.Height {
-		minimapFogImage = ebiten.NewImage(fogRes.Width, fogRes.Height)
-	}
-
-	// Update the fog image's pixels based on the current state of the fog of war.
-	pixels := make([]byte, fogRes.Width*fogRes.Height*4)
-	for y := 0; y < fogRes.Height; y++ {
-		for x := 0; x < fogRes.Width; x++ {
-			idx := (y*fogRes.Width + x) * 4
-			switch fogRes.Grid[y][x] {
-			case fog.Hidden:
-				pixels[idx+3] = 255 // Black
-			case fog.Shroud:
-				pixels[idx+3] = 180 // Semi-transparent black
-			}
-		}
-	}
-	minimapFogImage.WritePixels(pixels)
-
-	// Draw the pre-rendered fog image over the minimap.
-	op := &ebiten.DrawImageOptions{}
+	// Draw the fog texture the main view uses over the minimap, without its border.
+	fogImage := fogTexture(fogRes).SubImage(image.Rect(1, 1, fogRes.Width+1, fogRes.Height+1)).(*ebiten.Image)
+	op := &ebiten.DrawImageOptions{Filter: ebiten.FilterLinear}
 	op.GeoM.Scale(float64(minimap.Width)/float64(fogRes.Width), float64(minimap.Height)/float64(fogRes.Height))
 	op.GeoM.Translate(float64(minimap.X), float64(minimap.Y))
-	screen.DrawImage(minimapFogImage, op)
+	screen.DrawImage(fogImage, op)
 
 	// Draw a rectangle on the minimap to represent the camera's current view.
 	camX := float32(minimap.X + int(cam.X*scaleX))
 	camY := float32(minimap.Y + int(cam.Y*scaleY))
-	camW := float32(float64(settings.ScreenWidth) * scaleX)
-	camH := float32(float64(settings.ScreenHeight) * scaleY)
+	viewW, viewH := cam.ViewSize(settings)
+	camW := float32(viewW * scaleX)
+	camH := float32(viewH * scaleY)
 	vector.StrokeRect(screen, camX, camY, camW, camH, 1, color.White, false)
 
 	// Draw a border around the minimap.
 	vector.StrokeRect(screen, float32(minimap.X), float32(minimap.Y), float32(minimap.Width), float32(minimap.Height), 1, color.White, false)
 
 }
+
+// minimapOffline reports whether the minimap is offline because the local player is low on power.
+func minimapOffline(w donburi.World) bool {
+	local := LocalPlayer(w)
+	return local != nil && lowPower(w, local.Faction)
+}
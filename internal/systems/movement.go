@@ -4,7 +4,9 @@ import (
 	"math"
 
 	"github.com/gfeyer/ebit/internal/components"
+	"github.com/gfeyer/ebit/internal/pathfinding"
 	"github.com/gfeyer/ebit/internal/settings"
+	"github.com/gfeyer/ebit/internal/terrain"
 	"github.com/yohamta/donburi"
 	"github.com/yohamta/donburi/ecs"
 	"github.com/yohamta/donburi/filter"
@@ -12,7 +14,7 @@ import (
 
 var (
 	// qMovers retrieves all entities that have position, velocity, and a target, making them capable of movement.
-	qMovers = donburi.NewQuery(filter.Contains(components.Position, components.Velocity, components.Sprite, components.TargetRes))
+	qMovers = donburi.NewQuery(filter.Contains(components.Position, components.Velocity, components.SizeRes, components.TargetRes, components.PathRes))
 	// qSettings retrieves the game settings entity.
 	qSettings = donburi.NewQuery(filter.Contains(settings.SettingsRes))
 )
@@ -23,32 +25,56 @@ func UpdateMovement(ecs *ecs.ECS) {
 	const dt = 1.0 / 60.0
 
 	s := settings.GetSettings(ecs.World)
-	
+	ter := terrain.GetTerrain(ecs.World)
+
 	qMovers.Each(ecs.World, func(entry *donburi.Entry) {
 		p := components.Position.Get(entry)
 		v := components.Velocity.Get(entry)
 		t := components.TargetRes.Get(entry)
 
-		// If the entity has a target, calculate the velocity to move towards it.
+		// If the entity has a target, plan a path to it when the order changes, then steer towards the next waypoint.
 		if t.X != 0 || t.Y != 0 {
-			dx := t.X - p.X
-			dy := t.Y - p.Y
+			path := components.PathRes.Get(entry)
+			if path.Goal != *t {
+				updatePath(ecs, path, p, t)
+			}
+
+			// Head for the next waypoint, or straight for the target when no path could be planned.
+			next := components.Pos{X: t.X, Y: t.Y}
+			if len(path.Waypoints) > 0 {
+				next = path.Waypoints[0]
+			}
+			dx := next.X - p.X
+			dy := next.Y - p.Y
 			dist := math.Sqrt(dx*dx + dy*dy)
 
-			// If the unit is close enough to the target, stop moving.
-			if dist < 5 { // Arrived
-				v.X, v.Y = 0, 0
-				*t = components.Target{}
+			// If the unit is close enough to the waypoint, advance to the next one or stop at the end of the path.
+			if dist < 5 {
+				if len(path.Waypoints) > 1 {
+					path.Waypoints = path.Waypoints[1:]
+				} else { // Arrived
+					v.X, v.Y = 0, 0
+					*t = components.Target{}
+					*path = components.Path{}
+				}
 			} else {
-				// Otherwise, set the velocity to move towards the target at a constant speed.
-				v.X = (dx / dist) * 240
-				v.Y = (dy / dist) * 240
+				// Otherwise, set the velocity to move towards the waypoint at a constant speed.
+				speed := unitSpeed(entry)
+				v.X = (dx / dist) * speed
+				v.Y = (dy / dist) * speed
 			}
 		}
 
 		// Update the position based on the current velocity and the time delta.
-		p.X += v.X * dt
-		p.Y += v.Y * dt
+		// Each axis is applied separately so units slide along impassable terrain instead of sticking to it.
+		// A unit that is already stuck on impassable terrain is allowed to drive out of it.
+		stuck := !ter.At(p.X, p.Y).IsPassable()
+		if stuck || ter.At(p.X+v.X*dt, p.Y).IsPassable() {
+			p.X += v.X * dt
+		}
+		if stuck || ter.At(p.X, p.Y+v.Y*dt).IsPassable() {
+			p.Y += v.Y * dt
+		}
 
 		// Ensure the unit stays within the map boundaries.
 		if p.X < 0 {
@@ -57,11 +83,58 @@ func UpdateMovement(ecs *ecs.ECS) {
 		if p.Y < 0 {
 			p.Y = 0
 		}
-		if p.X > float64(s.MapWidth) {
-			p.X = float64(s.MapWidth)
+		if p.X > float64(s.MapWidth-1) {
+			p.X = float64(s.MapWidth - 1)
 		}
-		if p.Y > float64(s.MapHeight) {
-			p.Y = float64(s.MapHeight)
+		if p.Y > float64(s.MapHeight-1) {
+			p.Y = float64(s.MapHeight - 1)
 		}
 	})
 }
+
+// updatePath plans a new path towards the unit's target. When only the exact goal moved within the
+// same tile, the existing path is kept and its last waypoint is moved onto the new goal.
+func updatePath(ecs *ecs.ECS, path *components.Path, p *components.Pos, t *components.Target) {
+	ter := terrain.GetTerrain(ecs.World)
+	oldX, oldY := ter.TileAt(path.Goal.X, path.Goal.Y)
+	newX, newY := ter.TileAt(t.X, t.Y)
+	if len(path.Waypoints) > 0 && oldX == newX && oldY == newY {
+		path.Waypoints[len(path.Waypoints)-1] = components.Pos{X: t.X, Y: t.Y}
+		path.Goal = *t
+		return
+	}
+
+	grid := &pathfinding.Grid{Terrain: ter, Blocked: buildingObstacles(ecs.World, ter)}
+	path.Goal = *t
+	path.Waypoints = pathfinding.FindPath(grid, p.X, p.Y, t.X, t.Y)
+}
+
+// buildingObstacles marks every terrain tile covered by a building's footprint.
+func buildingObstacles(w donburi.World, ter *terrain.Terrain) [][]bool {
+	blocked := make([][]bool, ter.Height)
+	for y := range blocked {
+		blocked[y] = make([]bool, ter.Width)
+	}
+	qBuildings.Each(w, func(entry *donburi.Entry) {
+		p := components.Position.Get(entry)
+		width, height := entitySize(entry)
+		minX, minY := ter.TileAt(p.X, p.Y)
+		maxX, maxY := ter.TileAt(p.X+width-1, p.Y+height-1)
+		for y := minY; y <= maxY; y++ {
+			for x := minX; x <= maxX; x++ {
+				if ter.InBounds(x, y) {
+					blocked[y][x] = true
+				}
+			}
+		}
+	})
+	return blocked
+}
+
+// unitSpeed returns how fast a unit moves, in pixels per second. Entities that aren't units don't move on their own.
+func unitSpeed(entry *donburi.Entry) float64 {
+	if !entry.HasComponent(components.UnitRes) {
+		return 0
+	}
+	return components.UnitRes.Get(entry).Speed
+}
@@ -0,0 +1,53 @@
+package systems
+
+import (
+	"github.com/gfeyer/ebit/internal/components"
+	"github.com/yohamta/donburi"
+)
+
+// orderMove sends a unit to a world position, cancelling any attack or harvest order it had.
+func orderMove(entry *donburi.Entry, x, y float64) {
+	if entry.HasComponent(components.AttackRes) {
+		components.AttackRes.Get(entry).Target = 0
+	}
+	*components.TargetRes.Get(entry) = components.Target{X: x, Y: y}
+	// If it was a harvester, clear its spice target
+	if entry.HasComponent(components.HarvesterRes) {
+		harvester := components.HarvesterRes.Get(entry)
+		harvester.State = components.StateIdle
+		harvester.TargetSpice = 0
+	}
+}
+
+// orderHarvest sends a harvester to gather spice from a spice field.
+func orderHarvest(entry *donburi.Entry, spice *donburi.Entry) {
+	harvester := components.HarvesterRes.Get(entry)
+	harvester.State = components.StateMovingToSpice
+	harvester.TargetSpice = spice.Entity()
+	spicePos := components.Position.Get(spice)
+	*components.TargetRes.Get(entry) = components.Target{X: spicePos.X, Y: spicePos.Y}
+}
+
+// orderAttack sends an armed unit to attack another unit or building.
+func orderAttack(entry *donburi.Entry, target *donburi.Entry) {
+	components.AttackRes.Get(entry).Target = target.Entity()
+	targetPos := components.Position.Get(target)
+	*components.TargetRes.Get(entry) = components.Target{X: targetPos.X, Y: targetPos.Y}
+}
+
+// orderStop makes a unit stand still, dropping any move, attack or harvest order.
+func orderStop(entry *donburi.Entry) {
+	*components.TargetRes.Get(entry) = components.Target{}
+	*components.Velocity.Get(entry) = components.Vel{}
+	if entry.HasComponent(components.PathRes) {
+		*components.PathRes.Get(entry) = components.Path{}
+	}
+	if entry.HasComponent(components.AttackRes) {
+		components.AttackRes.Get(entry).Target = 0
+	}
+	if entry.HasComponent(components.HarvesterRes) {
+		harvester := components.HarvesterRes.Get(entry)
+		harvester.State = components.StateIdle
+		harvester.TargetSpice = 0
+	}
+}
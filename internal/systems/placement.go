@@ -2,15 +2,93 @@ package systems
 
 import (
 	"image/color"
+	"math"
 
+	"github.com/gfeyer/ebit/internal/camera"
 	"github.com/gfeyer/ebit/internal/components"
+	"github.com/gfeyer/ebit/internal/factory"
+	"github.com/gfeyer/ebit/internal/fog"
+	"github.com/gfeyer/ebit/internal/input"
+	"github.com/gfeyer/ebit/internal/terrain"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/yohamta/donburi"
 	"github.com/yohamta/donburi/ecs"
 )
 
+// snapToTile aligns a world position to the top-left corner of the terrain tile it lies in.
+func snapToTile(w donburi.World, x, y float64) (float64, float64) {
+	ter := terrain.GetTerrain(w)
+	ts := float64(ter.TileSize)
+	return math.Floor(x/ts) * ts, math.Floor(y/ts) * ts
+}
+
+// canPlaceBuilding reports whether a faction may place a building of the given type with its top-left corner at (x, y).
+// The footprint must lie on buildable terrain, overlap no unit, building or spice field and be adjacent to one of the
+// faction's buildings: less than a terrain tile away from it. Fog isn't checked here: every machine in a match must come to the same answer,
+// and each one only knows its own player's fog. See isExplored.
+func canPlaceBuilding(w donburi.World, faction components.Faction, btype components.BuildingType, x, y float64) bool {
+	width, height := factory.BuildingSize(w, btype)
+
+	// The whole footprint must be on buildable terrain; tiles outside the map never are.
+	ter := terrain.GetTerrain(w)
+	minX, minY := ter.TileAt(x, y)
+	maxX, maxY := ter.TileAt(x+width-1, y+height-1)
+	for ty := minY; ty <= maxY; ty++ {
+		for tx := minX; tx <= maxX; tx++ {
+			if !ter.Tile(tx, ty).IsBuildable() {
+				return false
+			}
+		}
+	}
+
+	// Nothing may stand on the footprint, and one of the faction's own buildings must be next to it.
+	overlaps := func(entry *donburi.Entry) bool {
+		p := components.Position.Get(entry)
+		w, h := entitySize(entry)
+		return x < p.X+w && p.X < x+width && y < p.Y+h && p.Y < y+height
+	}
+	// Only units, buildings and spice fields within a tile of the footprint can matter.
+	free, adjacent := true, false
+	ts := float64(ter.TileSize)
+	entitiesNear(w, x-ts, y-ts, x+width+ts, y+height+ts, func(entry *donburi.Entry) {
+		if (isAttackable(entry) || entry.HasComponent(components.SpiceRes)) && overlaps(entry) {
+			free = false
+		}
+		if !entry.HasComponent(components.BuildingRes) || !IsOwnedBy(entry, faction) {
+			return
+		}
+		p := components.Position.Get(entry)
+		w, h := entitySize(entry)
+		// Measure the gap between the two rectangles, which is zero when they touch. Buildings placed in a match
+		// are aligned to the tiles and touch, but those placed on the map may be a fraction of a tile off.
+		gapX := math.Max(0, math.Max(p.X-(x+width), x-(p.X+w)))
+		gapY := math.Max(0, math.Max(p.Y-(y+height), y-(p.Y+h)))
+		if gapX < ts && gapY < ts {
+			adjacent = true
+		}
+	})
+	return free && adjacent
+}
+
+// isExplored reports whether the local player has seen all of a building's footprint with its top-left corner at (x, y).
+// The local player can't build on ground they have never seen; this is checked before the order is given.
+func isExplored(w donburi.World, btype components.BuildingType, x, y float64) bool {
+	width, height := factory.BuildingSize(w, btype)
+	fogRes := fog.GetFog(w)
+	for _, corner := range [4][2]float64{{x, y}, {x + width - 1, y}, {x, y + height - 1}, {x + width - 1, y + height - 1}} {
+		fx, fy := int(corner[0])/fogRes.TileSize, int(corner[1])/fogRes.TileSize
+		if fx < 0 || fx >= fogRes.Width || fy < 0 || fy >= fogRes.Height || fogRes.State(fx, fy) == fog.Hidden {
+			return false
+		}
+	}
+	return true
+}
+
 // DrawPlacement renders a preview of a building at the cursor's position when the player is in placement mode.
+// The footprint is drawn green where the building can be placed and red where it can't.
 func DrawPlacement(ecs *ecs.ECS, screen *ebiten.Image) {
+	in := input.GetInput(ecs.World)
 	placementEntry, ok := PlacementQuery.First(ecs.World)
 	if !ok {
 		return
@@ -19,15 +97,22 @@ func DrawPlacement(ecs *ecs.ECS, screen *ebiten.Image) {
 
 	// If the player is currently placing a building, draw its footprint at the mouse cursor.
 	if placement.IsPlacing {
-		mx, my := ebiten.CursorPosition()
-
-		// Get icon dimensions
-		bounds := placement.Icon.Bounds()
-		width := float32(bounds.Dx())
-		height := float32(bounds.Dy())
+		local := LocalPlayer(ecs.World)
+		if local == nil {
+			return
+		}
+		cameraEntry, _ := camera.CameraQuery.First(ecs.World)
+		cam := camera.CameraRes.Get(cameraEntry)
+		mx, my := in.CursorPosition()
+		wx, wy := cam.ScreenToWorld(float64(mx), float64(my))
+		wx, wy = snapToTile(ecs.World, wx, wy)
+		width, height := factory.BuildingSize(ecs.World, placement.BuildingType)
 
-		// Draw a white rectangle at the cursor's position to represent the building's footprint.
-		// Draw a white rectangle as the footprint
-		vector.DrawFilledRect(screen, float32(mx), float32(my), width, height, color.White, false)
+		footprintColor := color.RGBA{R: 255, A: 128}
+		if isExplored(ecs.World, placement.BuildingType, wx, wy) && canPlaceBuilding(ecs.World, local.Faction, placement.BuildingType, wx, wy) {
+			footprintColor = color.RGBA{G: 255, A: 128}
+		}
+		sx, sy := cam.WorldToScreen(wx, wy)
+		vector.DrawFilledRect(screen, float32(sx), float32(sy), float32(width*cam.Scale()), float32(height*cam.Scale()), footprintColor, false)
 	}
 }
@@ -0,0 +1,41 @@
+package systems
+
+import (
+	"github.com/gfeyer/ebit/internal/components"
+	"github.com/gfeyer/ebit/internal/defs"
+	"github.com/gfeyer/ebit/internal/factory"
+	"github.com/yohamta/donburi"
+	"github.com/yohamta/donburi/ecs"
+)
+
+// UpdatePower recomputes the power every player's buildings produce and consume, as their definitions describe.
+func UpdatePower(ecs *ecs.ECS) {
+	d := defs.GetDefs(ecs.World)
+	power := map[components.Faction]*components.Power{}
+	QPlayer.Each(ecs.World, func(entry *donburi.Entry) {
+		player := components.PlayerRes.Get(entry)
+		player.Power = components.Power{}
+		power[player.Faction] = &player.Power
+	})
+
+	qBuildings.Each(ecs.World, func(entry *donburi.Entry) {
+		btype, _ := factory.BuildingTypeOf(entry)
+		def := d.Building(btype)
+		p := power[components.OwnerRes.Get(entry).Faction]
+		if def == nil || p == nil {
+			return
+		}
+		if def.Power > 0 {
+			p.Produced += def.Power
+		} else {
+			p.Consumed -= def.Power
+		}
+	})
+}
+
+// lowPower reports whether a faction's buildings consume more power than they produce. On low power,
+// production runs at half speed, defensive structures hold their fire and the minimap goes offline.
+func lowPower(w donburi.World, faction components.Faction) bool {
+	player := GetPlayer(w, faction)
+	return player != nil && player.Power.Consumed > player.Power.Produced
+}
@@ -0,0 +1,183 @@
+package systems
+
+import (
+	"github.com/gfeyer/ebit/internal/command"
+	"github.com/gfeyer/ebit/internal/components"
+	"github.com/gfeyer/ebit/internal/factory"
+	"github.com/gfeyer/ebit/internal/rng"
+	"github.com/gfeyer/ebit/internal/spatial"
+	"github.com/yohamta/donburi"
+	"github.com/yohamta/donburi/ecs"
+	"github.com/yohamta/donburi/filter"
+)
+
+// maxQueueLength is the number of units a building can have waiting in its production queue.
+const maxQueueLength = 5
+
+var (
+	// qProduction retrieves all buildings that can train units.
+	qProduction = donburi.NewQuery(filter.Contains(components.Position, components.ProductionRes, components.OwnerRes))
+	// qConstruction retrieves all players that can construct buildings.
+	qConstruction = donburi.NewQuery(filter.Contains(components.PlayerRes, components.ConstructionRes))
+)
+
+// UpdateProduction advances the production queue of every building and the construction of every player.
+// A unit is spawned next to its building once its build time has elapsed; a constructed building becomes
+// ready to be placed. Production and construction stand still while their owner lacks a prerequisite,
+// and only advance every other tick while their owner is low on power.
+func UpdateProduction(ecs *ecs.ECS) {
+	slow := command.GetBuffer(ecs.World).Tick%2 == 1
+	qProduction.Each(ecs.World, func(entry *donburi.Entry) {
+		production := components.ProductionRes.Get(entry)
+		if len(production.Queue) == 0 {
+			return
+		}
+
+		order := production.Queue[0]
+		faction := components.OwnerRes.Get(entry).Faction
+		if unitLocked(ecs.World, faction, order.Type) || (slow && lowPower(ecs.World, faction)) {
+			return
+		}
+		production.Progress++
+		if production.Progress < order.BuildTime {
+			return
+		}
+
+		spawnUnit(ecs.World, entry, order.Type)
+		production.Queue = production.Queue[1:]
+		production.Progress = 0
+	})
+
+	qConstruction.Each(ecs.World, func(entry *donburi.Entry) {
+		construction := components.ConstructionRes.Get(entry)
+		if !construction.Active || construction.Ready {
+			return
+		}
+		faction := components.PlayerRes.Get(entry).Faction
+		if buildingLocked(ecs.World, faction, construction.Type) || (slow && lowPower(ecs.World, faction)) {
+			return
+		}
+		construction.Progress++
+		if construction.Progress >= construction.BuildTime {
+			construction.Ready = true
+		}
+	})
+}
+
+// spawnUnit creates a unit for the owner of a building, next to that building.
+func spawnUnit(w donburi.World, building *donburi.Entry, utype components.UnitType) {
+	owner := components.OwnerRes.Get(building).Faction
+	buildingPos := components.Position.Get(building)
+	r := rng.GetRNG(w)
+	spawnX := buildingPos.X + float64(r.Intn(64)) + 32 // Spawn to the right of the building
+	spawnY := buildingPos.Y + float64(r.Intn(64)) + 32
+	factory.CreateUnit(w, owner, utype, spawnX, spawnY)
+}
+
+// enqueueUnit deducts the cost of a unit from the funds of the building's owner and adds it to the
+// building's production queue. It returns false if the owner cannot afford it or the queue is full.
+func enqueueUnit(w donburi.World, building *donburi.Entry, unitInfo *components.UnitInfo) bool {
+	production := components.ProductionRes.Get(building)
+	player := GetPlayer(w, components.OwnerRes.Get(building).Faction)
+	if player == nil || player.Money < unitInfo.Cost || len(production.Queue) >= maxQueueLength {
+		return false
+	}
+	player.Money -= unitInfo.Cost
+	production.Queue = append(production.Queue, components.ProductionOrder{
+		Type:      unitInfo.Type,
+		Cost:      unitInfo.Cost,
+		BuildTime: unitInfo.BuildTime,
+	})
+	return true
+}
+
+// cancelUnit removes the most recently queued unit of a type from a building's queue and refunds its cost.
+func cancelUnit(w donburi.World, building *donburi.Entry, utype components.UnitType) bool {
+	production := components.ProductionRes.Get(building)
+	for i := len(production.Queue) - 1; i >= 0; i-- {
+		order := production.Queue[i]
+		if order.Type != utype {
+			continue
+		}
+		if player := GetPlayer(w, components.OwnerRes.Get(building).Faction); player != nil {
+			player.Money += order.Cost
+		}
+		production.Queue = append(production.Queue[:i], production.Queue[i+1:]...)
+		// Cancelling the unit under construction throws away its progress.
+		if i == 0 {
+			production.Progress = 0
+		}
+		return true
+	}
+	return false
+}
+
+// queuedCount returns how many units of a type are waiting in a building's queue.
+func queuedCount(production *components.Production, utype components.UnitType) int {
+	count := 0
+	for _, order := range production.Queue {
+		if order.Type == utype {
+			count++
+		}
+	}
+	return count
+}
+
+// getConstruction returns the construction state of a faction, or nil if it cannot construct buildings.
+func getConstruction(w donburi.World, faction components.Faction) *components.Construction {
+	var construction *components.Construction
+	qConstruction.Each(w, func(entry *donburi.Entry) {
+		if components.PlayerRes.Get(entry).Faction == faction {
+			construction = components.ConstructionRes.Get(entry)
+		}
+	})
+	return construction
+}
+
+// startConstruction deducts the cost of a building from a faction's funds and starts constructing it.
+// Only one building can be under construction at a time.
+func startConstruction(w donburi.World, faction components.Faction, buildInfo *components.BuildInfo) bool {
+	player := GetPlayer(w, faction)
+	construction := getConstruction(w, faction)
+	if player == nil || construction == nil || construction.Active || player.Money < buildInfo.Cost {
+		return false
+	}
+	player.Money -= buildInfo.Cost
+	*construction = components.Construction{
+		Active:    true,
+		Type:      buildInfo.Type,
+		Cost:      buildInfo.Cost,
+		BuildTime: buildInfo.BuildTime,
+	}
+	return true
+}
+
+// cancelConstruction stops the construction of a building and refunds its cost.
+func cancelConstruction(w donburi.World, faction components.Faction) bool {
+	player := GetPlayer(w, faction)
+	construction := getConstruction(w, faction)
+	if player == nil || construction == nil || !construction.Active {
+		return false
+	}
+	player.Money += construction.Cost
+	*construction = components.Construction{}
+	return true
+}
+
+// placeBuilding places a faction's finished construction at a world position, snapped to the terrain grid.
+// It returns false without placing anything if no construction is ready, the building is locked or the site is not valid.
+func placeBuilding(w donburi.World, faction components.Faction, x, y float64) bool {
+	construction := getConstruction(w, faction)
+	if construction == nil || !construction.Ready || buildingLocked(w, faction, construction.Type) {
+		return false
+	}
+	x, y = snapToTile(w, x, y)
+	if !canPlaceBuilding(w, faction, construction.Type, x, y) {
+		return false
+	}
+	// Index the building right away, so another building placed in the same tick can't overlap it.
+	e := factory.CreateBuilding(w, faction, construction.Type, x, y)
+	indexEntity(spatial.GetGrid(w), w.Entry(e))
+	*construction = components.Construction{}
+	return true
+}
@@ -10,12 +10,16 @@ var (
 	// QSelectable retrieves all entities that can be selected by the player, including units and buildings.
 	QSelectable = donburi.NewQuery(filter.And(
 		filter.Contains(components.Position, components.SelectableRes),
-		filter.Or(filter.Contains(components.UnitRes), filter.Contains(components.RefineryRes)),
+		filter.Or(filter.Contains(components.UnitRes), filter.Contains(components.BuildingRes)),
 	))
 	// QDrag retrieves the entity that manages the state of the drag-selection box.
 	QDrag = donburi.NewQuery(filter.Contains(components.DragRes))
 	// QSpice retrieves all spice fields on the map.
-	QSpice = donburi.NewQuery(filter.Contains(components.SpiceRes, components.Position, components.Sprite))
+	QSpice = donburi.NewQuery(filter.Contains(components.SpiceRes, components.Position, components.SizeRes))
+	// QAttackable retrieves all units and buildings that have health and can be attacked.
+	QAttackable = donburi.NewQuery(filter.Contains(components.Position, components.SizeRes, components.SelectableRes, components.HealthRes))
+	// qBuildings retrieves all buildings of every player.
+	qBuildings = donburi.NewQuery(filter.Contains(components.Position, components.SizeRes, components.BuildingRes))
 	// QPlayer retrieves the player's entity, used for accessing resources like money.
 	QPlayer = donburi.NewQuery(filter.Contains(components.PlayerRes))
 
@@ -30,3 +34,84 @@ var (
 		filter.Contains(components.Position, components.SelectableRes, components.UnitRes),
 	))
 )
+
+// GetPlayer returns the player of the given faction, or nil if that faction is not in the game.
+func GetPlayer(w donburi.World, faction components.Faction) *components.Player {
+	var player *components.Player
+	QPlayer.Each(w, func(entry *donburi.Entry) {
+		if p := components.PlayerRes.Get(entry); p.Faction == faction {
+			player = p
+		}
+	})
+	return player
+}
+
+// LocalPlayer returns the player controlled from this machine.
+func LocalPlayer(w donburi.World) *components.Player {
+	var player *components.Player
+	QPlayer.Each(w, func(entry *donburi.Entry) {
+		if p := components.PlayerRes.Get(entry); p.Local {
+			player = p
+		}
+	})
+	return player
+}
+
+// entitySize returns the width and height of an entity, or zero if it has no size.
+func entitySize(entry *donburi.Entry) (float64, float64) {
+	if !entry.HasComponent(components.SizeRes) {
+		return 0, 0
+	}
+	size := components.SizeRes.Get(entry)
+	return size.W, size.H
+}
+
+// containsPoint reports whether a world position lies within an entity's bounds.
+func containsPoint(entry *donburi.Entry, x, y float64) bool {
+	p := components.Position.Get(entry)
+	w, h := entitySize(entry)
+	return x >= p.X && x < p.X+w && y >= p.Y && y < p.Y+h
+}
+
+// isAttackable reports whether an entity is a unit or building that can be attacked, as retrieved by QAttackable.
+func isAttackable(entry *donburi.Entry) bool {
+	return entry.HasComponent(components.SelectableRes) && entry.HasComponent(components.HealthRes)
+}
+
+// IsOwnedBy reports whether an entity belongs to the given faction.
+func IsOwnedBy(entry *donburi.Entry, faction components.Faction) bool {
+	return entry.HasComponent(components.OwnerRes) && components.OwnerRes.Get(entry).Faction == faction
+}
+
+// findBuildInfo returns the build menu entry for a building type, or nil if it is not buildable.
+func findBuildInfo(w donburi.World, btype components.BuildingType) *components.BuildInfo {
+	var info *components.BuildInfo
+	BuildMenuQuery.Each(w, func(entry *donburi.Entry) {
+		if bi := components.BuildInfoRes.Get(entry); bi.Type == btype {
+			info = bi
+		}
+	})
+	return info
+}
+
+// trainsUnits reports whether any unit in the training menu is trained at a building type.
+func trainsUnits(w donburi.World, btype components.BuildingType) bool {
+	trains := false
+	UnitMenuQuery.Each(w, func(entry *donburi.Entry) {
+		if components.UnitInfoRes.Get(entry).RequiredBuilding == btype {
+			trains = true
+		}
+	})
+	return trains
+}
+
+// findUnitInfo returns the unit menu entry for a unit type, or nil if it cannot be trained.
+func findUnitInfo(w donburi.World, utype components.UnitType) *components.UnitInfo {
+	var info *components.UnitInfo
+	UnitMenuQuery.Each(w, func(entry *donburi.Entry) {
+		if ui := components.UnitInfoRes.Get(entry); ui.Type == utype {
+			info = ui
+		}
+	})
+	return info
+}
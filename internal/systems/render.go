@@ -5,8 +5,10 @@ import (
 )
 
 const (
+	// LayerTerrain is the rendering layer for the terrain tiles.
+	LayerTerrain ecs.LayerID = iota
 	// LayerSpice is the rendering layer for spice fields.
-	LayerSpice ecs.LayerID = iota
+	LayerSpice
 	// LayerBuildings is the rendering layer for buildings.
 	LayerBuildings
 	// LayerUnits is the rendering layer for units.
@@ -24,4 +26,3 @@ const (
 	// LayerFog is the rendering layer for the fog of war.
 	LayerFog
 )
-
@@ -0,0 +1,77 @@
+package systems
+
+import (
+	"math"
+
+	"github.com/gfeyer/ebit/internal/components"
+	"github.com/gfeyer/ebit/internal/settings"
+	"github.com/gfeyer/ebit/internal/spatial"
+	"github.com/yohamta/donburi"
+	"github.com/yohamta/donburi/ecs"
+	"github.com/yohamta/donburi/filter"
+)
+
+// qIndexed retrieves every entity kept in the spatial grid: units, buildings and spice fields.
+var qIndexed = donburi.NewQuery(filter.Contains(components.Position, components.SizeRes))
+
+// UpdateSpatial rebuilds the spatial grid from the positions of every unit, building and spice field. It runs
+// right after units have moved, so the systems after it can look entities up by position.
+func UpdateSpatial(ecs *ecs.ECS) {
+	grid := spatial.GetGrid(ecs.World)
+	s := settings.GetSettings(ecs.World)
+	// Loading a game can change the size of the map.
+	if fresh := spatial.NewGrid(s.MapWidth, s.MapHeight); fresh.Width != grid.Width || fresh.Height != grid.Height {
+		*grid = *fresh
+	}
+	grid.Clear()
+	qIndexed.Each(ecs.World, func(entry *donburi.Entry) {
+		indexEntity(grid, entry)
+	})
+}
+
+// indexEntity adds an entity to the spatial grid. Entities created after the grid was rebuilt are only found
+// once they are added, which matters where an entity must be seen in the same tick it was created.
+func indexEntity(grid *spatial.Grid, entry *donburi.Entry) {
+	p := components.Position.Get(entry)
+	w, h := entitySize(entry)
+	grid.Insert(entry.Entity(), p.X, p.Y, w, h)
+}
+
+// entitiesNear calls fn for every unit, building and spice field whose bounds may overlap a world rectangle,
+// including some just outside it. Entities removed since the grid was rebuilt are skipped.
+func entitiesNear(w donburi.World, x0, y0, x1, y1 float64, fn func(*donburi.Entry)) {
+	spatial.GetGrid(w).Query(x0, y0, x1, y1, func(e donburi.Entity) {
+		if w.Valid(e) {
+			fn(w.Entry(e))
+		}
+	})
+}
+
+// entitiesAt calls fn for every unit, building and spice field whose bounds contain a world position.
+func entitiesAt(w donburi.World, x, y float64, fn func(*donburi.Entry)) {
+	entitiesNear(w, x, y, x, y, func(entry *donburi.Entry) {
+		if containsPoint(entry, x, y) {
+			fn(entry)
+		}
+	})
+}
+
+// nearestEntity returns the entity accept returns true for whose position is closest to (x, y), and no further
+// than maxDist, or nil if there is none.
+func nearestEntity(w donburi.World, x, y, maxDist float64, accept func(*donburi.Entry) bool) *donburi.Entry {
+	e := spatial.GetGrid(w).Nearest(x, y, maxDist, func(e donburi.Entity) (float64, bool) {
+		if !w.Valid(e) {
+			return 0, false
+		}
+		entry := w.Entry(e)
+		if !accept(entry) {
+			return 0, false
+		}
+		p := components.Position.Get(entry)
+		return math.Hypot(p.X-x, p.Y-y), true
+	})
+	if e == donburi.Null {
+		return nil
+	}
+	return w.Entry(e)
+}
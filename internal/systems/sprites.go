@@ -13,18 +13,14 @@ import (
 )
 
 var (
-	// qUnits retrieves all unit entities that have a position and a sprite.
-	qUnits = donburi.NewQuery(filter.And(
+	// qUnitSprites retrieves all unit entities that have a position and a sprite.
+	qUnitSprites = donburi.NewQuery(filter.And(
 		filter.Contains(components.Position, components.Sprite, components.UnitRes),
 	))
-	// qBuildings retrieves all building entities that have a position and a sprite.
-	qBuildings = donburi.NewQuery(filter.And(
-		filter.Contains(components.Position, components.Sprite),
-		filter.Or(
-			filter.Contains(components.RefineryRes),
-			filter.Contains(components.BarracksRes),
-		),
-	))
+	// qBuildingSprites retrieves all building entities that have a position and a sprite.
+	qBuildingSprites = donburi.NewQuery(filter.Contains(components.Position, components.Sprite, components.BuildingRes))
+	// qSpiceSprites retrieves all spice fields that have a sprite.
+	qSpiceSprites = donburi.NewQuery(filter.Contains(components.Position, components.Sprite, components.SpiceRes))
 )
 
 // DrawBuildings renders all building sprites to the screen.
@@ -33,7 +29,7 @@ func DrawBuildings(ecs *ecs.ECS, screen *ebiten.Image) {
 	cameraEntry, _ := camera.CameraQuery.First(ecs.World)
 	cam := camera.CameraRes.Get(cameraEntry)
 
-	qBuildings.Each(ecs.World, func(entry *donburi.Entry) {
+	qBuildingSprites.Each(ecs.World, func(entry *donburi.Entry) {
 		p := components.Position.Get(entry)
 		img := components.Sprite.Get(entry)
 
@@ -43,7 +39,8 @@ func DrawBuildings(ecs *ecs.ECS, screen *ebiten.Image) {
 		}
 
 		op := &ebiten.DrawImageOptions{}
-		op.GeoM.Translate(p.X-cam.X, p.Y-cam.Y)
+		op.GeoM.Translate(p.X, p.Y)
+		op.GeoM.Concat(cam.GeoM())
 
 		// Apply a green tint if the building is selected.
 		if entry.HasComponent(components.SelectableRes) && components.SelectableRes.Get(entry).Selected {
@@ -64,7 +61,7 @@ func DrawSpice(ecs *ecs.ECS, screen *ebiten.Image) {
 	cameraEntry, _ := camera.CameraQuery.First(ecs.World)
 	cam := camera.CameraRes.Get(cameraEntry)
 
-	qSpice.Each(ecs.World, func(entry *donburi.Entry) {
+	qSpiceSprites.Each(ecs.World, func(entry *donburi.Entry) {
 		p := components.Position.Get(entry)
 		img := components.Sprite.Get(entry)
 
@@ -74,7 +71,8 @@ func DrawSpice(ecs *ecs.ECS, screen *ebiten.Image) {
 		}
 
 		op := &ebiten.DrawImageOptions{}
-		op.GeoM.Translate(p.X-cam.X, p.Y-cam.Y)
+		op.GeoM.Translate(p.X, p.Y)
+		op.GeoM.Concat(cam.GeoM())
 		screen.DrawImage(*img, op)
 	})
 }
@@ -85,28 +83,37 @@ func DrawUnits(ecs *ecs.ECS, screen *ebiten.Image) {
 	cameraEntry, _ := camera.CameraQuery.First(ecs.World)
 	cam := camera.CameraRes.Get(cameraEntry)
 
-	qUnits.Each(ecs.World, func(entry *donburi.Entry) {
+	qUnitSprites.Each(ecs.World, func(entry *donburi.Entry) {
 		p := components.Position.Get(entry)
 		img := components.Sprite.Get(entry)
 
-		// Culling: Don't draw sprites that are outside the camera's view.
-		if !isSpriteInView(p, *img, cam, screen) {
+		// Culling: Don't draw sprites that are outside the camera's view, or enemy units the player can't see.
+		if !isSpriteInView(p, *img, cam, screen) || !inSight(ecs.World, entry) {
 			return
 		}
 
 		op := &ebiten.DrawImageOptions{}
 
-		// If the unit is moving, rotate its sprite to face the direction of movement.
+		// Units with directional frames are drawn with the frame closest to their direction of movement;
+		// any other moving unit has its sprite rotated to face the direction of movement.
 		v := components.Velocity.Get(entry)
-		if v.X != 0 || v.Y != 0 {
+		if entry.HasComponent(components.FramesRes) {
+			frames := components.FramesRes.Get(entry)
+			if v.X != 0 || v.Y != 0 {
+				frames.Facing = facingFrame(v, len(frames.Images))
+			}
+			img = &frames.Images[frames.Facing]
+			op.GeoM.Translate(p.X, p.Y)
+		} else if v.X != 0 || v.Y != 0 {
 			bounds := (*img).Bounds()
 			centerX, centerY := float64(bounds.Dx())/2, float64(bounds.Dy())/2
 			op.GeoM.Translate(-centerX, -centerY)
 			op.GeoM.Rotate(math.Atan2(v.Y, v.X) + math.Pi/2)
-			op.GeoM.Translate(p.X-cam.X+centerX, p.Y-cam.Y+centerY)
+			op.GeoM.Translate(p.X+centerX, p.Y+centerY)
 		} else {
-			op.GeoM.Translate(p.X-cam.X, p.Y-cam.Y)
+			op.GeoM.Translate(p.X, p.Y)
 		}
+		op.GeoM.Concat(cam.GeoM())
 
 		// Apply a green tint if the unit is selected.
 		if components.SelectableRes.Get(entry).Selected {
@@ -122,10 +129,22 @@ func DrawUnits(ecs *ecs.ECS, screen *ebiten.Image) {
 	})
 }
 
+// facingFrame returns which of n directional frames, clockwise starting from facing up, best matches a velocity.
+func facingFrame(v *components.Vel, n int) int {
+	// The angle of the velocity, clockwise from up
+	angle := math.Atan2(v.X, -v.Y)
+	frame := int(math.Round(angle/(2*math.Pi)*float64(n))) % n
+	if frame < 0 {
+		frame += n
+	}
+	return frame
+}
+
 // isSpriteInView checks if a sprite is currently within the camera's viewport.
 // This is used for culling to avoid rendering off-screen objects.
 func isSpriteInView(p *components.Pos, img *ebiten.Image, cam *camera.Camera, screen *ebiten.Image) bool {
 	screenW, screenH := screen.Bounds().Dx(), screen.Bounds().Dy()
+	viewW, viewH := float64(screenW)/cam.Scale(), float64(screenH)/cam.Scale()
 	spriteW, spriteH := img.Bounds().Dx(), img.Bounds().Dy()
 
 	// Bounding box of the object in world coordinates.
@@ -136,9 +155,9 @@ func isSpriteInView(p *components.Pos, img *ebiten.Image, cam *camera.Camera, sc
 
 	// Bounding box of the camera in world coordinates.
 	camLeft := cam.X
-	camRight := cam.X + float64(screenW)
+	camRight := cam.X + viewW
 	camTop := cam.Y
-	camBottom := cam.Y + float64(screenH)
+	camBottom := cam.Y + viewH
 
 	// Check if the object's bounding box is outside the camera's bounding box.
 	if objRight < camLeft || objLeft > camRight || objBottom < camTop || objTop > camBottom {
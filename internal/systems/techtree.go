@@ -0,0 +1,64 @@
+package systems
+
+import (
+	"github.com/gfeyer/ebit/internal/components"
+	"github.com/gfeyer/ebit/internal/factory"
+	"github.com/yohamta/donburi"
+)
+
+// missingPrerequisite returns the first of a menu entry's prerequisites that a faction doesn't own, and false if
+// it owns them all. Nothing is remembered: an entry is locked again as soon as a building it needs is lost.
+func missingPrerequisite(w donburi.World, faction components.Faction, prerequisites []components.BuildingType) (components.BuildingType, bool) {
+	if len(prerequisites) == 0 {
+		return 0, false
+	}
+	owned := map[components.BuildingType]bool{}
+	qBuildings.Each(w, func(entry *donburi.Entry) {
+		if btype, ok := factory.BuildingTypeOf(entry); ok && IsOwnedBy(entry, faction) {
+			owned[btype] = true
+		}
+	})
+	for _, btype := range prerequisites {
+		if !owned[btype] {
+			return btype, true
+		}
+	}
+	return 0, false
+}
+
+// unitLocked reports whether a faction lacks a building it needs to train a unit type.
+func unitLocked(w donburi.World, faction components.Faction, utype components.UnitType) bool {
+	info := findUnitInfo(w, utype)
+	if info == nil {
+		return true
+	}
+	_, missing := missingPrerequisite(w, faction, info.Prerequisites)
+	return missing
+}
+
+// missingForConstruction returns the first building a faction lacks to construct a building from the menu, and
+// false if it has them all. The Construction Yard owns the construction menu, so every building needs one.
+func missingForConstruction(w donburi.World, faction components.Faction, info *components.BuildInfo) (components.BuildingType, bool) {
+	if _, missing := missingPrerequisite(w, faction, []components.BuildingType{components.BuildingConstructionYard}); missing {
+		return components.BuildingConstructionYard, true
+	}
+	return missingPrerequisite(w, faction, info.Prerequisites)
+}
+
+// buildingLocked reports whether a faction lacks a building it needs to construct a building type.
+func buildingLocked(w donburi.World, faction components.Faction, btype components.BuildingType) bool {
+	info := findBuildInfo(w, btype)
+	if info == nil {
+		return true
+	}
+	_, missing := missingForConstruction(w, faction, info)
+	return missing
+}
+
+// buildingName returns the name a building type has in the construction menu.
+func buildingName(w donburi.World, btype components.BuildingType) string {
+	if info := findBuildInfo(w, btype); info != nil {
+		return info.Name
+	}
+	return "?"
+}
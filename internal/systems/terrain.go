@@ -0,0 +1,72 @@
+package systems
+
+import (
+	"image/color"
+
+	"github.com/gfeyer/ebit/internal/assets"
+	"github.com/gfeyer/ebit/internal/camera"
+	"github.com/gfeyer/ebit/internal/settings"
+	"github.com/gfeyer/ebit/internal/terrain"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/yohamta/donburi/ecs"
+)
+
+// terrainColors maps each terrain type to the color it is drawn with.
+var terrainColors = map[terrain.TerrainType]color.RGBA{
+	terrain.Sand:      {210, 180, 140, 255},
+	terrain.Rock:      {128, 108, 88, 255},
+	terrain.Dunes:     {196, 160, 112, 255},
+	terrain.Mountain:  {72, 58, 46, 255},
+	terrain.SpiceSand: {200, 130, 80, 255},
+}
+
+// terrainAssets maps each terrain type to its sprite on the sprite sheets.
+var terrainAssets = map[terrain.TerrainType]string{
+	terrain.Sand:      "terrain/sand",
+	terrain.Rock:      "terrain/rock",
+	terrain.Dunes:     "terrain/dunes",
+	terrain.Mountain:  "terrain/mountain",
+	terrain.SpiceSand: "terrain/spice",
+}
+
+// terrainTile returns the image a terrain type is drawn with at the given tile size, or nil if it has no sprite
+// and is drawn in its color instead.
+func terrainTile(tt terrain.TerrainType, tileSize int) *ebiten.Image {
+	frames := assets.Frames(terrainAssets[tt], color.RGBA{}, tileSize, tileSize)
+	if frames == nil {
+		return nil
+	}
+	return frames[0]
+}
+
+// DrawTerrain renders the terrain tiles that are inside the camera's view.
+func DrawTerrain(ecs *ecs.ECS, screen *ebiten.Image) {
+	cameraEntry, _ := camera.CameraQuery.First(ecs.World)
+	cam := camera.CameraRes.Get(cameraEntry)
+	t := terrain.GetTerrain(ecs.World)
+	s := settings.GetSettings(ecs.World)
+
+	// Only walk the tiles covered by the screen instead of the whole map.
+	viewW, viewH := cam.ViewSize(s)
+	minX, minY := t.TileAt(cam.X, cam.Y)
+	maxX, maxY := t.TileAt(cam.X+viewW, cam.Y+viewH)
+
+	tileSize := float32(float64(t.TileSize) * cam.Scale())
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			if !t.InBounds(x, y) {
+				continue
+			}
+			if tile := terrainTile(t.Grid[y][x], t.TileSize); tile != nil {
+				op := &ebiten.DrawImageOptions{}
+				op.GeoM.Translate(float64(x*t.TileSize), float64(y*t.TileSize))
+				op.GeoM.Concat(cam.GeoM())
+				screen.DrawImage(tile, op)
+				continue
+			}
+			screenX, screenY := cam.WorldToScreen(float64(x*t.TileSize), float64(y*t.TileSize))
+			vector.DrawFilledRect(screen, float32(screenX), float32(screenY), tileSize, tileSize, terrainColors[t.Grid[y][x]], false)
+		}
+	}
+}
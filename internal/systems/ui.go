@@ -6,6 +6,7 @@ import (
 
 	"github.com/gfeyer/ebit/internal/camera"
 	"github.com/gfeyer/ebit/internal/components"
+	"github.com/gfeyer/ebit/internal/factory"
 	"github.com/gfeyer/ebit/internal/settings"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/text"
@@ -24,10 +25,8 @@ var (
 	))
 	// qHarvesterUI retrieves all Harvester units for their UI.
 	qHarvesterUI = donburi.NewQuery(filter.Contains(components.Position, components.Sprite, components.UnitRes, components.HealthRes, components.HarvesterRes))
-	// qRefineryUI retrieves all Refinery buildings for their UI.
-	qRefineryUI = donburi.NewQuery(filter.Contains(components.Position, components.Sprite, components.RefineryRes))
-	// qBarracksUI retrieves all Barracks buildings for their UI.
-	qBarracksUI = donburi.NewQuery(filter.Contains(components.Position, components.Sprite, components.BarracksRes))
+	// qBuildingUI retrieves all buildings for their UI.
+	qBuildingUI = donburi.NewQuery(filter.Contains(components.Position, components.Sprite, components.BuildingRes))
 )
 
 // DrawUI renders all the in-game user interface elements, such as health bars, unit labels, and resource counters.
@@ -35,69 +34,76 @@ func DrawUI(ecs *ecs.ECS, screen *ebiten.Image) {
 	cameraEntry, _ := camera.CameraQuery.First(ecs.World)
 	cam := camera.CameraRes.Get(cameraEntry)
 
-	// Display the player's current amount of money at the top-left of the screen.
-	playerEntry, ok := QPlayer.First(ecs.World)
-	if ok {
-		player := components.PlayerRes.Get(playerEntry)
+	// Display the local player's current amount of money at the top-left of the screen, with their power next to it.
+	if player := LocalPlayer(ecs.World); player != nil {
 		moneyText := fmt.Sprintf("$%d", player.Money)
 		text.Draw(screen, moneyText, basicfont.Face7x13, 10, 20, color.White)
+		drawPowerBar(screen, player.Power, 90, 10)
 	}
 
 	// Draw health bars and labels for all Trike units.
 	qTrikeUI.Each(ecs.World, func(entry *donburi.Entry) {
+		if !inSight(ecs.World, entry) {
+			return
+		}
 		p := components.Position.Get(entry)
 		img := components.Sprite.Get(entry)
 		health := components.HealthRes.Get(entry)
-		barWidth := float32((*img).Bounds().Dx())
+		barWidth := float32(float64((*img).Bounds().Dx()) * cam.Scale())
+		x, y := cam.WorldToScreen(p.X, p.Y)
+		screenX := float32(x)
 
 		// Health bar
-		healthBarY := float32(p.Y-cam.Y) - 8
+		healthBarY := float32(y) - 8
 		healthPercentage := float32(health.Current) / float32(health.Max)
-		vector.DrawFilledRect(screen, float32(p.X-cam.X), healthBarY, barWidth, 4, color.RGBA{R: 255, A: 255}, false)
-		vector.DrawFilledRect(screen, float32(p.X-cam.X), healthBarY, barWidth*healthPercentage, 4, color.RGBA{G: 255, A: 255}, false)
+		vector.DrawFilledRect(screen, screenX, healthBarY, barWidth, 4, color.RGBA{R: 255, A: 255}, false)
+		vector.DrawFilledRect(screen, screenX, healthBarY, barWidth*healthPercentage, 4, color.RGBA{G: 255, A: 255}, false)
 
 		// Unit label
 		labelY := int(healthBarY) - 2
-		text.Draw(screen, "Trike", basicfont.Face7x13, int(p.X-cam.X), labelY, color.White)
+		text.Draw(screen, "Trike", basicfont.Face7x13, int(screenX), labelY, labelColor(ecs.World, entry))
 	})
 
 	// Draw health bars, spice capacity bars, and labels for all Harvester units.
 	qHarvesterUI.Each(ecs.World, func(entry *donburi.Entry) {
+		if !inSight(ecs.World, entry) {
+			return
+		}
 		p := components.Position.Get(entry)
 		img := components.Sprite.Get(entry)
 		health := components.HealthRes.Get(entry)
-		barWidth := float32((*img).Bounds().Dx())
+		barWidth := float32(float64((*img).Bounds().Dx()) * cam.Scale())
+		x, y := cam.WorldToScreen(p.X, p.Y)
+		screenX := float32(x)
 
 		// Health bar
-		healthBarY := float32(p.Y-cam.Y) - 12
+		healthBarY := float32(y) - 12
 		healthPercentage := float32(health.Current) / float32(health.Max)
-		vector.DrawFilledRect(screen, float32(p.X-cam.X), healthBarY, barWidth, 4, color.RGBA{R: 255, A: 255}, false)
-		vector.DrawFilledRect(screen, float32(p.X-cam.X), healthBarY, barWidth*healthPercentage, 4, color.RGBA{G: 255, A: 255}, false)
+		vector.DrawFilledRect(screen, screenX, healthBarY, barWidth, 4, color.RGBA{R: 255, A: 255}, false)
+		vector.DrawFilledRect(screen, screenX, healthBarY, barWidth*healthPercentage, 4, color.RGBA{G: 255, A: 255}, false)
 
 		// Spice bar for Harvester
 		harvester := components.HarvesterRes.Get(entry)
 		spiceBarY := healthBarY + 5
 		spicePercentage := float32(harvester.CarriedAmount) / float32(harvester.Capacity)
-		vector.DrawFilledRect(screen, float32(p.X-cam.X), spiceBarY, barWidth, 4, color.RGBA{R: 64, G: 64, B: 64, A: 255}, false)
-		vector.DrawFilledRect(screen, float32(p.X-cam.X), spiceBarY, barWidth*spicePercentage, 4, color.RGBA{R: 255, G: 140, B: 0, A: 255}, false)
+		vector.DrawFilledRect(screen, screenX, spiceBarY, barWidth, 4, color.RGBA{R: 64, G: 64, B: 64, A: 255}, false)
+		vector.DrawFilledRect(screen, screenX, spiceBarY, barWidth*spicePercentage, 4, color.RGBA{R: 255, G: 140, B: 0, A: 255}, false)
 
 		// Unit label
 		labelY := int(healthBarY) - 2
-		text.Draw(screen, "Harvester", basicfont.Face7x13, int(p.X-cam.X), labelY, color.White)
-	})
-
-	// Draw labels for all Refinery buildings.
-	qRefineryUI.Each(ecs.World, func(entry *donburi.Entry) {
-		p := components.Position.Get(entry)
-		labelY := int(p.Y-cam.Y) - 2
-		text.Draw(screen, "Refinery", basicfont.Face7x13, int(p.X-cam.X), labelY, color.White)
+		text.Draw(screen, "Harvester", basicfont.Face7x13, int(screenX), labelY, labelColor(ecs.World, entry))
 	})
 
-	// Draw labels for all Barracks buildings.
-	qBarracksUI.Each(ecs.World, func(entry *donburi.Entry) {
+	// Draw labels and health bars for the buildings the local player has seen; the fog covers the rest.
+	qBuildingUI.Each(ecs.World, func(entry *donburi.Entry) {
+		if !explored(ecs.World, entry) {
+			return
+		}
 		p := components.Position.Get(entry)
-		labelY := int(p.Y-cam.Y) - 2
-		text.Draw(screen, "Barracks", basicfont.Face7x13, int(p.X-cam.X), labelY, color.White)
+		x, y := cam.WorldToScreen(p.X, p.Y)
+		name := buildingName(ecs.World, components.BuildingRes.Get(entry).Type)
+		text.Draw(screen, name, basicfont.Face7x13, int(x), int(y)-2, labelColor(ecs.World, entry))
+		drawBuildingHealth(screen, entry, cam)
 	})
 
 	// If the player is drag-selecting, draw the selection rectangle.
@@ -114,3 +120,56 @@ func DrawUI(ecs *ecs.ECS, screen *ebiten.Image) {
 	fpsText := fmt.Sprintf("FPS: %.2f", ebiten.ActualFPS())
 	text.Draw(screen, fpsText, basicfont.Face7x13, s.ScreenWidth-80, s.ScreenHeight-10, color.White)
 }
+
+// drawPowerBar draws how much of a player's power is in use as a bar with its top-left corner at (x, y),
+// followed by the consumed and produced amounts. The bar turns red when the player is low on power.
+func drawPowerBar(screen *ebiten.Image, power components.Power, x, y int) {
+	const barWidth, barHeight = 100, 10
+	var used float32
+	if power.Produced > 0 {
+		used = min(float32(power.Consumed)/float32(power.Produced), 1)
+	} else if power.Consumed > 0 {
+		used = 1
+	}
+	barColor := color.RGBA{G: 200, A: 255}
+	if power.Consumed > power.Produced {
+		barColor = color.RGBA{R: 220, A: 255}
+	}
+	vector.DrawFilledRect(screen, float32(x), float32(y), barWidth, barHeight, color.RGBA{R: 64, G: 64, B: 64, A: 255}, false)
+	vector.DrawFilledRect(screen, float32(x), float32(y), barWidth*used, barHeight, barColor, false)
+	vector.StrokeRect(screen, float32(x), float32(y), barWidth, barHeight, 1, color.White, false)
+
+	powerText := fmt.Sprintf("Power %d/%d", power.Consumed, power.Produced)
+	text.Draw(screen, powerText, basicfont.Face7x13, x+barWidth+6, y+barHeight, color.White)
+}
+
+// drawBuildingHealth draws a health bar along the bottom edge of a building once it has taken damage.
+func drawBuildingHealth(screen *ebiten.Image, entry *donburi.Entry, cam *camera.Camera) {
+	if !entry.HasComponent(components.HealthRes) {
+		return
+	}
+	health := components.HealthRes.Get(entry)
+	if health.Current >= health.Max {
+		return
+	}
+	p := components.Position.Get(entry)
+	img := components.Sprite.Get(entry)
+	x, y := cam.WorldToScreen(p.X, p.Y)
+	barWidth := float32(float64((*img).Bounds().Dx()) * cam.Scale())
+	barY := float32(y+float64((*img).Bounds().Dy())*cam.Scale()) - 4
+	healthPercentage := float32(health.Current) / float32(health.Max)
+	vector.DrawFilledRect(screen, float32(x), barY, barWidth, 4, color.RGBA{R: 255, A: 255}, false)
+	vector.DrawFilledRect(screen, float32(x), barY, barWidth*healthPercentage, 4, color.RGBA{G: 255, A: 255}, false)
+}
+
+// labelColor returns the color of an entity's label: white for the local player, the faction color for everyone else.
+func labelColor(w donburi.World, entry *donburi.Entry) color.Color {
+	if !entry.HasComponent(components.OwnerRes) {
+		return color.White
+	}
+	faction := components.OwnerRes.Get(entry).Faction
+	if local := LocalPlayer(w); local != nil && local.Faction == faction {
+		return color.White
+	}
+	return factory.FactionColor(faction)
+}
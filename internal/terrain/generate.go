@@ -0,0 +1,36 @@
+package terrain
+
+import (
+	"github.com/gfeyer/ebit/internal/rng"
+)
+
+// Generate fills the grid with a random desert: dune fields, rock plateaus with mountain
+// ridges, and a flat rock plateau around each start position so a base can be built there.
+// The layout depends only on the state of the random number generator.
+func (t *Terrain) Generate(r *rng.RNG, starts ...[2]float64) {
+	mapW := float64(t.Width * t.TileSize)
+	mapH := float64(t.Height * t.TileSize)
+
+	// Scatter dune fields across the open sand.
+	for i := 0; i < 12; i++ {
+		x := r.Float64() * mapW
+		y := r.Float64() * mapH
+		t.Fill(x, y, 96+r.Float64()*160, Dunes)
+	}
+
+	// Raise rock plateaus, some of them topped with an impassable mountain ridge.
+	for i := 0; i < 8; i++ {
+		x := r.Float64() * mapW
+		y := r.Float64() * mapH
+		radius := 128 + r.Float64()*192
+		t.Fill(x, y, radius, Rock)
+		if r.Intn(2) == 0 {
+			t.Fill(x, y, radius*0.4, Mountain)
+		}
+	}
+
+	// Clear a rock plateau around every start position.
+	for _, s := range starts {
+		t.Fill(s[0], s[1], 320, Rock)
+	}
+}
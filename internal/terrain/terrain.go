@@ -0,0 +1,116 @@
+package terrain
+
+import (
+	"math"
+
+	"github.com/gfeyer/ebit/internal/settings"
+	"github.com/yohamta/donburi"
+	"github.com/yohamta/donburi/filter"
+)
+
+type TerrainType int
+
+const (
+	Sand      TerrainType = iota // Open desert, passable but not buildable
+	Rock                         // Solid ground, the only terrain buildings can be placed on
+	Dunes                        // Rolling sand, passable but not buildable
+	Mountain                     // Impassable cliffs
+	SpiceSand                    // Sand that carries spice
+)
+
+// IsPassable reports whether ground units can drive over the terrain.
+func (t TerrainType) IsPassable() bool {
+	return t != Mountain
+}
+
+// IsBuildable reports whether buildings can be placed on the terrain.
+func (t TerrainType) IsBuildable() bool {
+	return t == Rock
+}
+
+// Terrain is a resource that holds the terrain grid of the map.
+type Terrain struct {
+	Grid     [][]TerrainType
+	TileSize int
+	Width    int
+	Height   int
+}
+
+var TerrainRes = donburi.NewComponentType[Terrain]()
+
+// NewTerrain creates a terrain grid covering the whole map, filled with sand.
+func NewTerrain(s *settings.Settings, tileSize int) *Terrain {
+	width := s.MapWidth / tileSize
+	height := s.MapHeight / tileSize
+	grid := make([][]TerrainType, height)
+	for i := range grid {
+		grid[i] = make([]TerrainType, width)
+	}
+	return &Terrain{
+		Grid:     grid,
+		TileSize: tileSize,
+		Width:    width,
+		Height:   height,
+	}
+}
+
+// GetTerrain gets the terrain from the world.
+func GetTerrain(w donburi.World) *Terrain {
+	entry, _ := donburi.NewQuery(filter.Contains(TerrainRes)).First(w)
+	return TerrainRes.Get(entry)
+}
+
+// InBounds reports whether the tile coordinates are inside the grid.
+func (t *Terrain) InBounds(tx, ty int) bool {
+	return tx >= 0 && tx < t.Width && ty >= 0 && ty < t.Height
+}
+
+// TileAt converts world coordinates to tile coordinates.
+func (t *Terrain) TileAt(x, y float64) (int, int) {
+	return int(math.Floor(x / float64(t.TileSize))), int(math.Floor(y / float64(t.TileSize)))
+}
+
+// Tile returns the terrain type of a tile. Tiles outside the map are treated as mountains.
+func (t *Terrain) Tile(tx, ty int) TerrainType {
+	if !t.InBounds(tx, ty) {
+		return Mountain
+	}
+	return t.Grid[ty][tx]
+}
+
+// At returns the terrain type at the given world position.
+func (t *Terrain) At(x, y float64) TerrainType {
+	return t.Tile(t.TileAt(x, y))
+}
+
+// Fill sets every tile within the circle (in world coordinates) to the given terrain type.
+func (t *Terrain) Fill(cx, cy, radius float64, tt TerrainType) {
+	minX, minY := t.TileAt(cx-radius, cy-radius)
+	maxX, maxY := t.TileAt(cx+radius, cy+radius)
+	for ty := minY; ty <= maxY; ty++ {
+		for tx := minX; tx <= maxX; tx++ {
+			if !t.InBounds(tx, ty) {
+				continue
+			}
+			// Measure from the tile center so the circle is symmetric.
+			dx := (float64(tx)+0.5)*float64(t.TileSize) - cx
+			dy := (float64(ty)+0.5)*float64(t.TileSize) - cy
+			if dx*dx+dy*dy <= radius*radius {
+				t.Grid[ty][tx] = tt
+			}
+		}
+	}
+}
+
+// FillRect sets every tile overlapping the rectangle (in world coordinates) to the given terrain type.
+func (t *Terrain) FillRect(x, y, w, h float64, tt TerrainType) {
+	minX, minY := t.TileAt(x, y)
+	maxX, maxY := t.TileAt(x+w-1, y+h-1)
+	for ty := minY; ty <= maxY; ty++ {
+		for tx := minX; tx <= maxX; tx++ {
+			if t.InBounds(tx, ty) {
+				t.Grid[ty][tx] = tt
+			}
+		}
+	}
+}